@@ -0,0 +1,151 @@
+// Package auth authenticates requests to the HTTP server and decides which role they have.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is what an authenticated request may do. RoleAdmin includes RoleRead.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	RoleNone  Role = "none"
+	RoleRead  Role = "read"
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole parses the name of a role.
+func ParseRole(s string) (Role, error) {
+	switch role := Role(s); role {
+	case RoleNone, RoleRead, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("invalid role %q, must be %q, %q or %q", s, RoleNone, RoleRead, RoleAdmin)
+	}
+}
+
+// Allows reports whether r grants the required role.
+func (r Role) Allows(required Role) bool {
+	return r.level() >= required.level()
+}
+
+func (r Role) level() int {
+	switch r {
+	case RoleRead:
+		return 1
+	case RoleAdmin:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Methods a request can be authenticated with, see Principal.
+const (
+	MethodAnonymous  = "anonymous"
+	MethodToken      = "token"
+	MethodClientCert = "client_cert"
+	MethodJWT        = "jwt"
+)
+
+// Principal is who made a request and the role they were granted.
+type Principal struct {
+	// Name identifies the caller: the certificate name, the subject of the JWT, or
+	// a fixed name for tokens and anonymous requests, which carry no identity.
+	Name   string
+	Method string
+	Role   Role
+}
+
+// ErrInvalidCredentials is returned by Authenticate for a request whose credentials are not accepted.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Config configures an Authenticator.
+type Config struct {
+	// Tokens maps static bearer tokens to their role.
+	Tokens map[string]Role
+	// ClientCerts maps the common name or a DNS name of verified client certificates to their role.
+	ClientCerts map[string]Role
+	// JWT validates bearer tokens that are JWTs, if set.
+	JWT *JWTConfig
+	// Anonymous is the role of requests without credentials.
+	Anonymous Role
+}
+
+// Authenticator authenticates requests. It is immutable; build a new one to change the configuration.
+type Authenticator struct {
+	cfg Config
+	jwt *jwtValidator
+}
+
+// New creates an Authenticator. It loads the JWKS file if JWT validation is configured.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{cfg: cfg}
+	if cfg.JWT != nil {
+		validator, err := newJWTValidator(*cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = validator
+	}
+	return a, nil
+}
+
+// Authenticate returns who made r. A bearer token takes precedence over a client
+// certificate, and a request with neither is anonymous. A client certificate that
+// is not in the allow-list is authenticated with RoleNone.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return Principal{}, fmt.Errorf("%w: expected a bearer token", ErrInvalidCredentials)
+		}
+		return a.authenticateToken(token)
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return a.authenticateCert(r)
+	}
+
+	return Principal{Name: MethodAnonymous, Method: MethodAnonymous, Role: a.cfg.Anonymous}, nil
+}
+
+// authenticateToken checks token against the static tokens, then as a JWT.
+func (a *Authenticator) authenticateToken(token string) (Principal, error) {
+	role, found := RoleNone, false
+	// Compare against every token so the time taken does not reveal which one matched.
+	for candidate, candidateRole := range a.cfg.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			role, found = candidateRole, true
+		}
+	}
+	if found {
+		return Principal{Name: MethodToken, Method: MethodToken, Role: role}, nil
+	}
+
+	if a.jwt != nil && strings.Count(token, ".") == 2 {
+		subject, role, err := a.jwt.validate(token)
+		if err != nil {
+			return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+		return Principal{Name: subject, Method: MethodJWT, Role: role}, nil
+	}
+	return Principal{}, fmt.Errorf("%w: unknown bearer token", ErrInvalidCredentials)
+}
+
+// authenticateCert looks up the verified client certificate of r in the allow-list.
+func (a *Authenticator) authenticateCert(r *http.Request) (Principal, error) {
+	cert := r.TLS.VerifiedChains[0][0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if role, ok := a.cfg.ClientCerts[name]; ok && name != "" {
+			return Principal{Name: name, Method: MethodClientCert, Role: role}, nil
+		}
+	}
+	return Principal{Name: cert.Subject.CommonName, Method: MethodClientCert, Role: RoleNone}, nil
+}
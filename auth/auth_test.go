@@ -0,0 +1,228 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+)
+
+var _ = Describe("Authenticator", func() {
+	var cfg auth.Config
+
+	BeforeEach(func() {
+		cfg = auth.Config{
+			Tokens:      map[string]auth.Role{"reader": auth.RoleRead, "s3cret": auth.RoleAdmin},
+			ClientCerts: map[string]auth.Role{"ops.example.com": auth.RoleAdmin},
+			Anonymous:   auth.RoleRead,
+		}
+	})
+
+	authenticate := func(setup func(r *http.Request)) (auth.Principal, error) {
+		authenticator, err := auth.New(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if setup != nil {
+			setup(r)
+		}
+		return authenticator.Authenticate(r)
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	It("should grant the role of a static token", func() {
+		principal, err := authenticate(bearer("s3cret"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Method).To(Equal(auth.MethodToken))
+		Expect(principal.Role).To(Equal(auth.RoleAdmin))
+
+		principal, err = authenticate(bearer("reader"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Role.Allows(auth.RoleRead)).To(BeTrue())
+		Expect(principal.Role.Allows(auth.RoleAdmin)).To(BeFalse())
+	})
+
+	It("should reject unknown tokens and other schemes", func() {
+		_, err := authenticate(bearer("guess"))
+		Expect(err).To(MatchError(auth.ErrInvalidCredentials))
+
+		_, err = authenticate(func(r *http.Request) { r.SetBasicAuth("admin", "s3cret") })
+		Expect(err).To(MatchError(auth.ErrInvalidCredentials))
+	})
+
+	It("should give requests without credentials the anonymous role", func() {
+		principal, err := authenticate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Method).To(Equal(auth.MethodAnonymous))
+		Expect(principal.Role).To(Equal(auth.RoleRead))
+
+		cfg.Anonymous = auth.RoleNone
+		principal, err = authenticate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Role.Allows(auth.RoleRead)).To(BeFalse())
+	})
+
+	Context("with a client certificate", func() {
+		withCert := func(cn string, dnsNames ...string) func(r *http.Request) {
+			return func(r *http.Request) {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}, DNSNames: dnsNames}
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			}
+		}
+
+		It("should grant the role of an allowed common name or DNS name", func() {
+			principal, err := authenticate(withCert("ops.example.com"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal).To(Equal(auth.Principal{Name: "ops.example.com", Method: auth.MethodClientCert, Role: auth.RoleAdmin}))
+
+			principal, err = authenticate(withCert("ops", "ops.example.com"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal.Role).To(Equal(auth.RoleAdmin))
+		})
+
+		It("should grant no role to certificates outside the allow-list", func() {
+			principal, err := authenticate(withCert("intruder.example.com"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal.Role).To(Equal(auth.RoleNone))
+		})
+
+		It("should prefer a bearer token", func() {
+			principal, err := authenticate(func(r *http.Request) {
+				withCert("ops.example.com")(r)
+				bearer("reader")(r)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal.Method).To(Equal(auth.MethodToken))
+			Expect(principal.Role).To(Equal(auth.RoleRead))
+		})
+	})
+
+	Context("with JWT validation", func() {
+		var (
+			rsaKey *rsa.PrivateKey
+			ecKey  *ecdsa.PrivateKey
+			claims map[string]any
+		)
+
+		BeforeEach(func() {
+			var err error
+			rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+			path := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+			Expect(os.WriteFile(path, jwks, 0o600)).To(Succeed())
+
+			cfg.JWT = &auth.JWTConfig{JWKSFile: path, Issuer: "https://issuer.example.com", Audience: "gopher-updater", RolesClaim: "roles"}
+			claims = map[string]any{
+				"iss":   "https://issuer.example.com",
+				"aud":   []string{"gopher-updater", "other"},
+				"sub":   "alice",
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"roles": []string{"read", "admin"},
+			}
+		})
+
+		signRSA := func(kid string) string {
+			signed := segment(map[string]string{"alg": "RS256", "kid": kid}) + "." + segment(claims)
+			digest := sha256.Sum256([]byte(signed))
+			signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+			Expect(err).NotTo(HaveOccurred())
+			return signed + "." + encode(signature)
+		}
+
+		It("should grant the roles of a valid RS256 token", func() {
+			principal, err := authenticate(bearer(signRSA("rsa")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal).To(Equal(auth.Principal{Name: "alice", Method: auth.MethodJWT, Role: auth.RoleAdmin}))
+		})
+
+		It("should accept a valid ES256 token", func() {
+			claims["roles"] = "read"
+			signed := segment(map[string]string{"alg": "ES256", "kid": "ec"}) + "." + segment(claims)
+			digest := sha256.Sum256([]byte(signed))
+			r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+			Expect(err).NotTo(HaveOccurred())
+			signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+			principal, err := authenticate(bearer(signed + "." + encode(signature)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal.Role).To(Equal(auth.RoleRead))
+		})
+
+		It("should grant no role without the roles claim", func() {
+			delete(claims, "roles")
+			principal, err := authenticate(bearer(signRSA("rsa")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal.Role).To(Equal(auth.RoleNone))
+		})
+
+		DescribeTable("should reject invalid tokens",
+			func(modify func(), message string) {
+				modify()
+				_, err := authenticate(bearer(signRSA("rsa")))
+				Expect(err).To(MatchError(auth.ErrInvalidCredentials))
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("expired", func() { claims["exp"] = time.Now().Add(-time.Hour).Unix() }, "expired"),
+			Entry("without expiry", func() { delete(claims, "exp") }, "no expiry"),
+			Entry("wrong issuer", func() { claims["iss"] = "https://evil.example.com" }, "unexpected JWT issuer"),
+			Entry("wrong audience", func() { claims["aud"] = "other" }, "audience"),
+		)
+
+		It("should reject a token signed by another key", func() {
+			var err error
+			rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = authenticate(bearer(signRSA("rsa")))
+			Expect(err).To(MatchError(ContainSubstring("signature does not match")))
+		})
+
+		It("should reject unsigned tokens", func() {
+			token := segment(map[string]string{"alg": "none"}) + "." + segment(claims) + "."
+			_, err := authenticate(bearer(token))
+			Expect(err).To(MatchError(ContainSubstring(`unsupported JWT algorithm "none"`)))
+		})
+
+		It("should fail to start with an unreadable JWKS file", func() {
+			cfg.JWT.JWKSFile = filepath.Join(GinkgoT().TempDir(), "missing.json")
+			_, err := auth.New(cfg)
+			Expect(err).To(MatchError(ContainSubstring("failed to read JWKS file")))
+		})
+	})
+})
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func segment(v any) string {
+	data, err := json.Marshal(v)
+	Expect(err).NotTo(HaveOccurred())
+	return encode(data)
+}
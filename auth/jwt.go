@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // register the hashes used by the supported algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// JWTConfig configures the validation of JWTs issued by an OIDC provider.
+type JWTConfig struct {
+	// JWKSFile is the path of a JSON Web Key Set with the public keys of the issuer.
+	JWKSFile string
+	// Issuer must match the iss claim.
+	Issuer string
+	// Audience must be one of the aud claim.
+	Audience string
+	// RolesClaim names the claim, a string or a list of strings, that holds the roles of the subject.
+	RolesClaim string
+}
+
+// clockSkew is how far apart the clocks of the issuer and the server may be.
+const clockSkew = time.Minute
+
+// algorithms are the supported signing algorithms. Symmetric and unsigned tokens are never accepted.
+var algorithms = map[string]struct {
+	kty  string
+	hash crypto.Hash
+}{
+	"RS256": {"RSA", crypto.SHA256},
+	"RS384": {"RSA", crypto.SHA384},
+	"RS512": {"RSA", crypto.SHA512},
+	"PS256": {"RSA", crypto.SHA256},
+	"PS384": {"RSA", crypto.SHA384},
+	"PS512": {"RSA", crypto.SHA512},
+	"ES256": {"EC", crypto.SHA256},
+	"ES384": {"EC", crypto.SHA384},
+	"ES512": {"EC", crypto.SHA512},
+}
+
+type jwtValidator struct {
+	cfg  JWTConfig
+	keys []jwk
+}
+
+// jwk is a public key of a JSON Web Key Set.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	key crypto.PublicKey
+}
+
+func newJWTValidator(cfg JWTConfig) (*jwtValidator, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("the issuer and the audience are required to validate JWTs")
+	}
+	data, err := os.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %w", cfg.JWKSFile, err)
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %q of JWKS file %s: %w", key.Kid, cfg.JWKSFile, err)
+		}
+		key.key = publicKey
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s has no signing keys", cfg.JWKSFile)
+	}
+	return &jwtValidator{cfg: cfg, keys: keys}, nil
+}
+
+// publicKey decodes the RSA or EC public key of k.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// validate checks the signature and the claims of token, and returns its subject and role.
+func (v *jwtValidator) validate(token string) (string, Role, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", RoleNone, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", RoleNone, fmt.Errorf("invalid JWT signature encoding: %w", err)
+	}
+	if err := v.verify(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return "", RoleNone, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", RoleNone, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return "", RoleNone, err
+	}
+	subject, _ := claims["sub"].(string)
+	return subject, v.role(claims[v.cfg.RolesClaim]), nil
+}
+
+// verify checks the signature of signed with the key kid, or every key of a matching type if kid is empty.
+func (v *jwtValidator) verify(alg, kid, signed string, signature []byte) error {
+	algorithm, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+	hasher := algorithm.hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	for _, key := range v.keys {
+		if (kid != "" && key.Kid != kid) || key.Kty != algorithm.kty || (key.Alg != "" && key.Alg != alg) {
+			continue
+		}
+		if verifySignature(alg, algorithm.hash, key.key, digest, signature) {
+			return nil
+		}
+	}
+	return errors.New("JWT signature does not match any key")
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes ECDSA signatures as the concatenation of r and s.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// checkClaims checks the validity period, the issuer and the audience of a token.
+func (v *jwtValidator) checkClaims(claims map[string]any) error {
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("JWT has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return errors.New("JWT has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("JWT is not valid yet")
+	}
+	if issuer, _ := claims["iss"].(string); issuer != v.cfg.Issuer {
+		return fmt.Errorf("unexpected JWT issuer %q", issuer)
+	}
+	if !slices.Contains(stringList(claims["aud"]), v.cfg.Audience) {
+		return errors.New("JWT audience does not include this server")
+	}
+	return nil
+}
+
+// role returns the most privileged role listed in the roles claim.
+func (v *jwtValidator) role(claim any) Role {
+	role := RoleNone
+	for _, name := range stringList(claim) {
+		if candidate := Role(name); candidate.level() > role.level() {
+			role = candidate
+		}
+	}
+	return role
+}
+
+// stringList returns a claim that is either a string or a list of strings as a list.
+func stringList(claim any) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []any:
+		list := make([]string, 0, len(claim))
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
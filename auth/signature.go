@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSignatureAge is how old, or how far in the future, the timestamp of a signed request may be.
+const MaxSignatureAge = 5 * time.Minute
+
+// Sign returns the signature of a webhook body sent at timestamp, in Unix seconds:
+// "sha256=" followed by the hex HMAC-SHA256, keyed with secret, of the timestamp, a
+// dot and the body. Binding the timestamp limits how long a request can be replayed.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks that signature is the signature of body sent at timestamp,
+// see Sign, and that timestamp is at most MaxSignatureAge away from now.
+func VerifySignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("%w: missing or malformed signature", ErrInvalidCredentials)
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or malformed timestamp", ErrInvalidCredentials)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)).Abs(); age > MaxSignatureAge {
+		return fmt.Errorf("%w: timestamp is %s away from now", ErrInvalidCredentials, age.Round(time.Second))
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("%w: signature does not match", ErrInvalidCredentials)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package auth_test
+
+import (
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+)
+
+var _ = Describe("VerifySignature", func() {
+	var (
+		now       time.Time
+		timestamp string
+		body      []byte
+	)
+
+	BeforeEach(func() {
+		// Timestamps have a one second resolution.
+		now = time.Now().Truncate(time.Second)
+		timestamp = strconv.FormatInt(now.Unix(), 10)
+		body = []byte(`{"plan":"v1.2.3"}`)
+	})
+
+	It("should accept a request signed with the secret", func() {
+		signature := auth.Sign("s3cret", timestamp, body)
+		Expect(signature).To(HavePrefix("sha256="))
+		Expect(auth.VerifySignature("s3cret", timestamp, signature, body, now)).To(Succeed())
+	})
+
+	It("should reject a request signed with another secret", func() {
+		signature := auth.Sign("guess", timestamp, body)
+		Expect(auth.VerifySignature("s3cret", timestamp, signature, body, now)).To(MatchError(auth.ErrInvalidCredentials))
+	})
+
+	It("should reject a tampered body", func() {
+		signature := auth.Sign("s3cret", timestamp, body)
+		err := auth.VerifySignature("s3cret", timestamp, signature, []byte(`{"plan":"v9.9.9"}`), now)
+		Expect(err).To(MatchError(ContainSubstring("signature does not match")))
+	})
+
+	It("should reject old requests", func() {
+		signature := auth.Sign("s3cret", timestamp, body)
+		err := auth.VerifySignature("s3cret", timestamp, signature, body, now.Add(auth.MaxSignatureAge+time.Minute))
+		Expect(err).To(MatchError(ContainSubstring("timestamp is 6m0s away from now")))
+	})
+
+	It("should reject a missing signature or timestamp", func() {
+		Expect(auth.VerifySignature("s3cret", timestamp, "", body, now)).To(MatchError(auth.ErrInvalidCredentials))
+		Expect(auth.VerifySignature("s3cret", "", auth.Sign("s3cret", "", body), body, now)).To(MatchError(auth.ErrInvalidCredentials))
+	})
+})
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/gopher-lab/gopher-updater/leader"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/gopher-lab/gopher-updater/updater"
+	"github.com/labstack/echo/v4"
+)
+
+// registerAPI adds the admin API under /api/v1. Reading requires the read role and
+// actions the admin role, except for the approval webhook, which is authenticated by
+// its signature. With leader election, actions are only accepted by the leader.
+// Requests select the target with the target query parameter unless there is only one.
+func registerAPI(e *echo.Echo, targets []*target, authn *atomic.Pointer[auth.Authenticator], elector *leader.Elector) {
+	api := e.Group("/api/v1")
+	read, admin := requireRole(authn, auth.RoleRead), requireRole(authn, auth.RoleAdmin)
+	leading := requireLeader(elector)
+
+	api.GET("/plans", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		plans, err := t.updater.Plans(c.Request().Context())
+		if err != nil {
+			return apiError(http.StatusBadGateway, err)
+		}
+		return c.JSON(http.StatusOK, plans)
+	}, read)
+	api.GET("/chain", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		chain, err := t.updater.Chain(c.Request().Context())
+		if err != nil {
+			return apiError(http.StatusBadGateway, err)
+		}
+		return c.JSON(http.StatusOK, chain)
+	}, read)
+	api.POST("/check", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		xlog.Info("admin api: re-check requested", "target", t.name, "principal", principalName(c), "remote", c.RealIP())
+		t.updater.Recheck()
+		return c.JSON(http.StatusAccepted, map[string]string{"status": "check requested"})
+	}, admin, leading)
+	api.POST("/plans/:plan/retag", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		force, _ := strconv.ParseBool(c.QueryParam("force"))
+		xlog.Warn("admin api: manual retag requested", "target", t.name, "plan", c.Param("plan"), "force", force, "principal", principalName(c), "remote", c.RealIP())
+
+		// A retag must not be abandoned halfway because the client went away.
+		decision, err := t.updater.Promote(context.WithoutCancel(c.Request().Context()), c.Param("plan"), force)
+		switch {
+		case errors.Is(err, updater.ErrPlanNotFound):
+			return apiError(http.StatusNotFound, err)
+		case errors.Is(err, updater.ErrUpgradeNotReached):
+			return apiError(http.StatusConflict, err)
+		case err != nil && decision.Action == "":
+			return apiError(http.StatusBadGateway, err)
+		case err != nil:
+			return c.JSON(http.StatusBadGateway, decision)
+		}
+		return c.JSON(http.StatusOK, decision)
+	}, admin, leading)
+	api.POST("/plans/:plan/approve", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		xlog.Warn("admin api: approval requested", "target", t.name, "plan", c.Param("plan"), "principal", principalName(c), "remote", c.RealIP())
+		return approve(c, t, c.Param("plan"), "api:"+principalName(c))
+	}, admin, leading)
+	// The approval webhook is authenticated by its signature instead of a role.
+	api.POST("/approvals", func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBody))
+		if err != nil {
+			return apiError(http.StatusBadRequest, err)
+		}
+		var request struct {
+			Target string `json:"target"`
+			Plan   string `json:"plan"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil || request.Plan == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "the body must be a JSON object with the plan to approve")
+		}
+		t, err := selectTarget(targets, request.Target)
+		if err != nil {
+			return err
+		}
+
+		secret := t.config().ApprovalWebhookSecret
+		if secret == "" {
+			return echo.NewHTTPError(http.StatusForbidden, "the approval webhook is disabled, set APPROVAL_WEBHOOK_SECRET to enable it")
+		}
+		header := c.Request().Header
+		if err := auth.VerifySignature(secret, header.Get(headerTimestamp), header.Get(headerSignature), body, time.Now()); err != nil {
+			xlog.Warn("approval webhook: rejected request", "target", t.name, "plan", request.Plan, "remote", c.RealIP(), "err", err)
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		if err := checkLeader(elector); err != nil {
+			return err
+		}
+		xlog.Warn("approval webhook: approval requested", "target", t.name, "plan", request.Plan, "remote", c.RealIP())
+		return approve(c, t, request.Plan, "webhook")
+	})
+	api.POST("/pause", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		xlog.Warn("admin api: pause requested", "target", t.name, "principal", principalName(c), "remote", c.RealIP())
+		if err := t.updater.Pause(c.Request().Context()); err != nil {
+			return apiError(http.StatusBadGateway, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"paused": true})
+	}, admin, leading)
+	api.POST("/resume", func(c echo.Context) error {
+		t, err := apiTarget(c, targets)
+		if err != nil {
+			return err
+		}
+		xlog.Warn("admin api: resume requested", "target", t.name, "principal", principalName(c), "remote", c.RealIP())
+		if err := t.updater.Resume(c.Request().Context()); err != nil {
+			return apiError(http.StatusBadGateway, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"paused": false})
+	}, admin, leading)
+}
+
+// Headers of signed approval webhook requests, see auth.Sign.
+const (
+	headerTimestamp = "X-Gopher-Updater-Timestamp"
+	headerSignature = "X-Gopher-Updater-Signature"
+)
+
+// maxWebhookBody is the largest approval webhook body that is read.
+const maxWebhookBody = 64 << 10
+
+// approve approves plan for t and responds with the outcome.
+func approve(c echo.Context, t *target, plan, by string) error {
+	err := t.updater.Approve(c.Request().Context(), plan, by)
+	switch {
+	case errors.Is(err, updater.ErrPlanNotFound):
+		return apiError(http.StatusNotFound, err)
+	case err != nil:
+		return apiError(http.StatusBadGateway, err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"plan": plan, "approved_by": by})
+}
+
+// apiTarget returns the target selected by the target query parameter, which may be
+// omitted when there is only one target.
+func apiTarget(c echo.Context, targets []*target) (*target, error) {
+	return selectTarget(targets, c.QueryParam("target"))
+}
+
+// selectTarget returns the target named name, which may be empty when there is only one target.
+func selectTarget(targets []*target, name string) (*target, error) {
+	if name == "" && len(targets) == 1 {
+		return targets[0], nil
+	}
+	if name == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "the target is required with several targets")
+	}
+	t := findTarget(targets, name)
+	if t == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "unknown target")
+	}
+	return t, nil
+}
+
+// apiError returns err as an HTTP error with the given status code.
+func apiError(code int, err error) error {
+	return echo.NewHTTPError(code, err.Error())
+}
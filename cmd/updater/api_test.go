@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/leader"
+	"github.com/gopher-lab/gopher-updater/state"
+	"github.com/gopher-lab/gopher-updater/updater"
+)
+
+// fakeChain is a chain at height whose plans are all confirmed by the upgrade module.
+type fakeChain struct {
+	height int64
+	plans  []cosmos.Plan
+}
+
+func (f *fakeChain) GetLatestBlockHeight(context.Context) (int64, error) { return f.height, nil }
+
+func (f *fakeChain) GetBlockHeader(_ context.Context, height int64) (*cosmos.BlockHeader, error) {
+	return &cosmos.BlockHeader{Height: strconv.FormatInt(height, 10), Time: time.Unix(height, 0)}, nil
+}
+
+func (f *fakeChain) GetUpgradePlans(context.Context) ([]cosmos.Plan, error) { return f.plans, nil }
+
+func (f *fakeChain) GetCurrentPlan(context.Context) (*cosmos.Plan, error) { return nil, nil }
+
+func (f *fakeChain) GetAppliedPlanHeight(context.Context, string) (int64, error) { return 1, nil }
+
+// fakeRegistry resolves every tag and fails retags with retagErr.
+type fakeRegistry struct {
+	retagErr error
+}
+
+func (f *fakeRegistry) RetagImage(context.Context, string, string, string) error { return f.retagErr }
+
+func (f *fakeRegistry) TagExists(context.Context, string, string) (bool, error) { return false, nil }
+
+func (f *fakeRegistry) ManifestDigest(_ context.Context, _, tag string) (string, error) {
+	return "sha256:" + tag, nil
+}
+
+// heldLock is a leader.Lock held by another replica.
+type heldLock struct{}
+
+func (heldLock) TryAcquire(context.Context, string, time.Duration) (string, error) {
+	return "pod-b", nil
+}
+
+func (heldLock) Release(context.Context, string) error { return nil }
+
+var _ = Describe("registerAPI", func() {
+	const webhookSecret = "webhook-secret"
+
+	var (
+		e        *echo.Echo
+		targets  []*target
+		authn    atomic.Pointer[auth.Authenticator]
+		elector  *leader.Elector
+		registry *fakeRegistry
+	)
+
+	newTarget := func(name string, chain *fakeChain, opts ...updater.Option) *target {
+		cfg := &config.Config{
+			Target:                name,
+			RepoPath:              "my/repo",
+			SourcePrefix:          "release-",
+			TargetPrefix:          name + "-",
+			PollInterval:          time.Hour,
+			ApprovalWebhookSecret: webhookSecret,
+		}
+		return &target{name: name, updater: updater.New(chain, registry, cfg, opts...), cfg: cfg}
+	}
+
+	serve := func() {
+		e = echo.New()
+		registerAPI(e, targets, &authn, elector)
+	}
+
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	webhook := func(body, timestamp, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/approvals", strings.NewReader(body))
+		req.Header.Set(headerTimestamp, timestamp)
+		req.Header.Set(headerSignature, signature)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	sign := func(body string, at time.Time) (string, string) {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return timestamp, auth.Sign(webhookSecret, timestamp, []byte(body))
+	}
+
+	BeforeEach(func() {
+		authenticator, err := auth.New(auth.Config{
+			Tokens:    map[string]auth.Role{"read-token": auth.RoleRead, "admin-token": auth.RoleAdmin},
+			Anonymous: auth.RoleNone,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		authn.Store(authenticator)
+		elector = nil
+		registry = &fakeRegistry{}
+		targets = []*target{newTarget("testnet", &fakeChain{height: 150, plans: []cosmos.Plan{
+			{Name: "v1", Height: "100"},
+			{Name: "v2", Height: "200"},
+		}})}
+		serve()
+	})
+
+	Describe("roles", func() {
+		It("should require credentials", func() {
+			rec := request(http.MethodGet, "/api/v1/plans", "")
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rec.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal("Bearer"))
+
+			Expect(request(http.MethodGet, "/api/v1/plans", "wrong-token").Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should let the read role read but not act", func() {
+			Expect(request(http.MethodGet, "/api/v1/plans", "read-token").Code).To(Equal(http.StatusOK))
+			Expect(request(http.MethodGet, "/api/v1/chain", "read-token").Code).To(Equal(http.StatusOK))
+			Expect(request(http.MethodPost, "/api/v1/pause", "read-token").Code).To(Equal(http.StatusForbidden))
+			Expect(request(http.MethodPost, "/api/v1/plans/v1/retag", "read-token").Code).To(Equal(http.StatusForbidden))
+			Expect(request(http.MethodPost, "/api/v1/plans/v2/approve", "read-token").Code).To(Equal(http.StatusForbidden))
+			Expect(targets[0].updater.Status().Paused).To(BeFalse())
+		})
+
+		It("should let the admin role act", func() {
+			Expect(request(http.MethodGet, "/api/v1/plans", "admin-token").Code).To(Equal(http.StatusOK))
+			Expect(request(http.MethodPost, "/api/v1/pause", "admin-token").Code).To(Equal(http.StatusOK))
+			Expect(targets[0].updater.Status().Paused).To(BeTrue())
+			Expect(request(http.MethodPost, "/api/v1/resume", "admin-token").Code).To(Equal(http.StatusOK))
+			Expect(targets[0].updater.Status().Paused).To(BeFalse())
+			Expect(request(http.MethodPost, "/api/v1/check", "admin-token").Code).To(Equal(http.StatusAccepted))
+		})
+	})
+
+	Describe("leader election", func() {
+		BeforeEach(func() {
+			elector = leader.NewElector(heldLock{}, "pod-a", time.Second)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				elector.Run(ctx, func(context.Context) {})
+			}()
+			DeferCleanup(func() {
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+			Eventually(func() string { return currentLeader(elector) }).Should(Equal("pod-b"))
+			serve()
+		})
+
+		It("should refuse actions on a replica that is not the leader", func() {
+			rec := request(http.MethodPost, "/api/v1/pause", "admin-token")
+			Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(rec.Body.String()).To(ContainSubstring("pod-b"))
+			Expect(targets[0].updater.Status().Paused).To(BeFalse())
+
+			body := `{"plan":"v2"}`
+			timestamp, signature := sign(body, time.Now())
+			Expect(webhook(body, timestamp, signature).Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("should still serve reads", func() {
+			Expect(request(http.MethodGet, "/api/v1/plans", "read-token").Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("target selection", func() {
+		BeforeEach(func() {
+			targets = append(targets, newTarget("mainnet", &fakeChain{height: 150, plans: []cosmos.Plan{
+				{Name: "v9", Height: "100"},
+			}}))
+			serve()
+		})
+
+		It("should require the target with several targets", func() {
+			Expect(request(http.MethodGet, "/api/v1/plans", "read-token").Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should refuse an unknown target", func() {
+			Expect(request(http.MethodGet, "/api/v1/plans?target=devnet", "read-token").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should act on the selected target only", func() {
+			rec := request(http.MethodGet, "/api/v1/plans?target=mainnet", "read-token")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			var plans []updater.PlanStatus
+			Expect(json.Unmarshal(rec.Body.Bytes(), &plans)).To(Succeed())
+			Expect(plans).To(HaveLen(1))
+			Expect(plans[0].Name).To(Equal("v9"))
+
+			Expect(request(http.MethodPost, "/api/v1/pause?target=mainnet", "admin-token").Code).To(Equal(http.StatusOK))
+			Expect(targets[1].updater.Status().Paused).To(BeTrue())
+			Expect(targets[0].updater.Status().Paused).To(BeFalse())
+		})
+	})
+
+	Describe("status codes", func() {
+		It("should answer 404 for an unknown plan", func() {
+			Expect(request(http.MethodPost, "/api/v1/plans/v7/retag", "admin-token").Code).To(Equal(http.StatusNotFound))
+			Expect(request(http.MethodPost, "/api/v1/plans/v7/approve", "admin-token").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should answer 409 for a plan whose height was not reached unless forced", func() {
+			Expect(request(http.MethodPost, "/api/v1/plans/v2/retag", "admin-token").Code).To(Equal(http.StatusConflict))
+			Expect(request(http.MethodPost, "/api/v1/plans/v2/retag?force=true", "admin-token").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should answer 502 with the decision when the retag fails", func() {
+			registry.retagErr = errors.New("registry boom")
+
+			rec := request(http.MethodPost, "/api/v1/plans/v1/retag", "admin-token")
+			Expect(rec.Code).To(Equal(http.StatusBadGateway))
+			var decision updater.Decision
+			Expect(json.Unmarshal(rec.Body.Bytes(), &decision)).To(Succeed())
+			Expect(decision.Plan).To(Equal("v1"))
+			Expect(decision.Action).To(Equal(updater.ActionFailed))
+		})
+
+		It("should answer 502 when a pause cannot be stored", func() {
+			// A state file under a regular file cannot be written.
+			file := filepath.Join(GinkgoT().TempDir(), "file")
+			Expect(os.WriteFile(file, nil, 0o600)).To(Succeed())
+			store := state.NewFileStore(filepath.Join(file, "state.json"))
+			targets = []*target{newTarget("testnet", &fakeChain{height: 150}, updater.WithStateStore(store))}
+			serve()
+
+			Expect(request(http.MethodPost, "/api/v1/pause", "admin-token").Code).To(Equal(http.StatusBadGateway))
+			Expect(targets[0].updater.Status().Paused).To(BeTrue())
+		})
+	})
+
+	Describe("approval webhook", func() {
+		body := `{"plan":"v2"}`
+
+		It("should approve a plan with a valid signature", func() {
+			timestamp, signature := sign(body, time.Now())
+			rec := webhook(body, timestamp, signature)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring(`"approved_by":"webhook"`))
+		})
+
+		It("should refuse a bad signature", func() {
+			timestamp, _ := sign(body, time.Now())
+			_, signature := sign(`{"plan":"v1"}`, time.Now())
+			Expect(webhook(body, timestamp, signature).Code).To(Equal(http.StatusUnauthorized))
+			Expect(webhook(body, timestamp, "").Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should refuse an old timestamp", func() {
+			timestamp, signature := sign(body, time.Now().Add(-2*auth.MaxSignatureAge))
+			Expect(webhook(body, timestamp, signature).Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should refuse requests when no secret is configured", func() {
+			targets[0].cfg.ApprovalWebhookSecret = ""
+			timestamp, signature := sign(body, time.Now())
+			Expect(webhook(body, timestamp, signature).Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should refuse a body without a plan", func() {
+			timestamp, signature := sign(`{}`, time.Now())
+			Expect(webhook(`{}`, timestamp, signature).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// principalKey is the key of the authenticated auth.Principal in the echo context.
+const principalKey = "principal"
+
+// requireRole rejects requests that are not authenticated with at least role. The
+// authenticator is loaded on every request so a reload can change the credentials.
+func requireRole(authn *atomic.Pointer[auth.Authenticator], role auth.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := authn.Load().Authenticate(c.Request())
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if err != nil {
+				return err
+			}
+			if !principal.Role.Allows(role) {
+				if principal.Method == auth.MethodAnonymous {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+					return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+				}
+				return echo.NewHTTPError(http.StatusForbidden, "the "+string(role)+" role is required")
+			}
+			c.Set(principalKey, principal)
+			return next(c)
+		}
+	}
+}
+
+// principalName returns the name of the caller authenticated by requireRole.
+func principalName(c echo.Context) string {
+	principal, _ := c.Get(principalKey).(auth.Principal)
+	return principal.Name
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/leader"
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
+	"github.com/labstack/echo/v4"
+)
+
+// newElector creates the elector for the configured lock backend, or nil if leader
+// election is disabled. The identity defaults to the hostname, which is the pod name
+// in Kubernetes.
+func newElector(cfg *config.Config) (*leader.Elector, error) {
+	identity := cfg.LeaderElectionIdentity
+	if identity == "" && cfg.LeaderElection != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for LEADER_ELECTION_IDENTITY: %w", err)
+		}
+		identity = hostname
+	}
+
+	var lock leader.Lock
+	switch cfg.LeaderElection {
+	case config.LeaderElectionLease:
+		kubeClient, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		namespace := cfg.LeaderElectionNamespace
+		if namespace == "" {
+			namespace = kubeClient.Namespace
+		}
+		lock = leader.NewLeaseLock(kubeClient, namespace, cfg.LeaderElectionLeaseName)
+	case config.LeaderElectionFile:
+		lock = leader.NewFileLock(cfg.LeaderElectionLockFile)
+	default:
+		return nil, nil
+	}
+	return leader.NewElector(lock, identity, cfg.LeaderElectionLeaseDuration), nil
+}
+
+// runTargets runs the updater of every target independently until ctx is done.
+func runTargets(ctx context.Context, targets []*target) {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTarget(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// currentLeader returns the identity of the leader as last seen by elector, or an empty
+// string without leader election.
+func currentLeader(elector *leader.Elector) string {
+	if elector == nil {
+		return ""
+	}
+	_, holder := elector.Leader()
+	return holder
+}
+
+// checkLeader returns an error unless this replica is the leader or leader election is
+// disabled. Actions on another replica would not reach the running updaters.
+func checkLeader(elector *leader.Elector) error {
+	if elector == nil {
+		return nil
+	}
+	leading, holder := elector.Leader()
+	if leading {
+		return nil
+	}
+	if holder == "" {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "this replica is not the leader and no leader is known, retry later")
+	}
+	return echo.NewHTTPError(http.StatusServiceUnavailable, "this replica is not the leader, send the request to "+holder)
+}
+
+// requireLeader rejects requests with checkLeader.
+func requireLeader(elector *leader.Elector) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := checkLeader(elector); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
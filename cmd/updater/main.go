@@ -2,34 +2,48 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/gopher-lab/gopher-updater/auth"
 	"github.com/gopher-lab/gopher-updater/config"
 	"github.com/gopher-lab/gopher-updater/cosmos"
-	"github.com/gopher-lab/gopher-updater/dockerhub"
-	"github.com/gopher-lab/gopher-updater/health"
+	"github.com/gopher-lab/gopher-updater/leader"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/notify"
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
 	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/gopher-lab/gopher-updater/state"
 	"github.com/gopher-lab/gopher-updater/updater"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file, overridden by environment variables")
+	flag.Parse()
+
 	xlog.Info("starting gopher-updater")
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	cfg, err := config.New(ctx)
+	targetCfgs, err := config.NewTargets(ctx, *configFile)
 	if err != nil {
 		xlog.Error("failed to process config", "err", err)
 		os.Exit(1)
 	}
+	// HTTP settings are shared by all targets.
+	cfg := targetCfgs[0]
 
 	// Setup signal handling
 	c := make(chan os.Signal, 1)
@@ -48,71 +62,358 @@ func main() {
 		},
 	}
 
-	cosmosClient := cosmos.NewClient(cfg.RPCURL, httpClient)
-	dockerhubClient := dockerhub.NewClient(cfg.DockerHubUser, cfg.DockerHubPassword, httpClient)
-	checker := health.NewChecker(cosmosClient, dockerhubClient, cfg.RepoPath)
-
-	// Start HTTP server and set up graceful shutdown
-	e := startHTTPServer(cfg, checker, cancel)
-	defer func() {
-		xlog.Info("shutting down http server")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := e.Shutdown(shutdownCtx); err != nil {
-			xlog.Error("http server shutdown failed", "err", err)
+	targets := make([]*target, 0, len(targetCfgs))
+	for _, targetCfg := range targetCfgs {
+		t, err := newTarget(targetCfg, httpClient)
+		if err != nil {
+			xlog.Error("failed to set up target", "target", targetCfg.Target, "err", err)
+			os.Exit(1)
 		}
-	}()
+		defer t.closeNotifiers()
+		if targetCfg.DryRun {
+			xlog.Warn("dry run enabled, images will be resolved but never retagged", "target", targetCfg.Target)
+		}
+		targets = append(targets, t)
+	}
+
+	// The authenticator is shared by all targets and replaced on reload.
+	var authn atomic.Pointer[auth.Authenticator]
+	authenticator, err := auth.New(cfg.Auth())
+	if err != nil {
+		xlog.Error("failed to set up authentication", "err", err)
+		os.Exit(1)
+	}
+	authn.Store(authenticator)
+
+	elector, err := newElector(cfg)
+	if err != nil {
+		xlog.Error("failed to set up leader election", "err", err)
+		os.Exit(1)
+	}
 
-	upd := updater.New(cosmosClient, dockerhubClient, cfg)
+	metrics.LastSuccessfulConfigReload.SetToCurrentTime()
+	go watchReloads(ctx, *configFile, cfg.ConfigWatchInterval, targets, &authn, httpClient)
 
-	// Run the main updater loop
+	// Start HTTP servers and set up graceful shutdown
+	e, err := startHTTPServer(cfg, targets, &authn, elector, cancel)
+	if err != nil {
+		xlog.Error("failed to set up http server", "err", err)
+		os.Exit(1)
+	}
+	defer shutdownHTTPServer(e)
+	if cfg.PprofAddr != "" {
+		defer shutdownHTTPServer(startPprofServer(cfg.PprofAddr, cancel))
+	}
+
+	// Run the updaters, only while leading with leader election. Every replica serves HTTP.
+	done := make(chan struct{})
 	go func() {
-		if err := upd.Run(ctx); err != nil && err != context.Canceled {
-			xlog.Error("updater failed", "err", err)
+		defer close(done)
+		defer cancel() // if all updaters stop for any reason, cancel the context
+		if elector == nil {
+			runTargets(ctx, targets)
+			return
 		}
-		cancel() // if the updater stops for any reason, cancel the context
+		xlog.Info("leader election enabled", "backend", cfg.LeaderElection, "identity", elector.Identity())
+		elector.Run(ctx, func(ctx context.Context) { runTargets(ctx, targets) })
 	}()
 
-	<-ctx.Done() // Wait for shutdown signal or updater to finish
+	<-ctx.Done() // Wait for shutdown signal or updaters to finish
+	<-done
 
 	xlog.Info("gopher-updater stopped gracefully")
 }
 
-func startHTTPServer(cfg *config.Config, checker *health.Checker, cancel context.CancelFunc) *echo.Echo {
+// newCosmosClient builds the Cosmos client for the configured endpoints, and the block
+// client the readiness checker should use. Multiple endpoints are wrapped in a failover client.
+func newCosmosClient(cfg *config.Config, httpClient *http.Client) (cosmos.ClientInterface, cosmos.BlockClient) {
+	endpoints := make([]cosmos.Endpoint, len(cfg.RPCURLs))
+	var blockClient cosmos.BlockClient
+	for i, rpcURL := range cfg.RPCURLs {
+		client := cosmos.NewClient(rpcURL, httpClient)
+		blockClient = client
+		if cfg.BlockBackend == config.BlockBackendCometBFT {
+			cometClient := cosmos.NewCometClient(cfg.CometRPCURLs[i], httpClient)
+			client.BlockClient = cometClient
+			blockClient = cometClient
+		}
+		endpoints[i] = cosmos.Endpoint{Name: rpcURL, Client: client}
+	}
+
+	if len(endpoints) == 1 && cfg.RPCQuorum <= 1 {
+		return endpoints[0].Client, blockClient
+	}
+	failoverClient := cosmos.NewFailoverClient(endpoints, cfg.RPCCooldown, cfg.RPCQuorum)
+	return failoverClient, failoverClient
+}
+
+// newStateStore creates the configured state store, or nil if none is configured.
+func newStateStore(cfg *config.Config) (state.Store, error) {
+	switch cfg.StateBackend {
+	case config.StateBackendFile:
+		return state.NewFileStore(cfg.StateFile), nil
+	case config.StateBackendConfigMap:
+		kubeClient, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		namespace := cfg.StateNamespace
+		if namespace == "" {
+			namespace = kubeClient.Namespace
+		}
+		return state.NewConfigMapStore(kubeClient, namespace, cfg.StateConfigMap), nil
+	default:
+		return nil, nil
+	}
+}
+
+// newNotifier creates a notifier for the configured sinks, or nil if none is configured.
+func newNotifier(cfg *config.Config, httpClient *http.Client) (*notify.Notifier, error) {
+	var sinks []notify.Sink
+	if cfg.NotifyWebhookURL != "" {
+		sinks = append(sinks, notify.NewWebhookSink(cfg.NotifyWebhookURL, httpClient))
+	}
+	if cfg.NotifySlackWebhookURL != "" {
+		sinks = append(sinks, notify.NewSlackSink(cfg.NotifySlackWebhookURL, httpClient))
+	}
+	if cfg.NotifyDiscordWebhookURL != "" {
+		sinks = append(sinks, notify.NewDiscordSink(cfg.NotifyDiscordWebhookURL, httpClient))
+	}
+	if cfg.NotifyTelegramBotToken != "" {
+		sinks = append(sinks, notify.NewTelegramSink(cfg.NotifyTelegramBotToken, cfg.NotifyTelegramChatID, httpClient))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	templates, err := notify.LoadTemplates(cfg.NotifyTemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := notify.NewNotifier(sinks, templates)
+	if err != nil {
+		return nil, err
+	}
+	notifier.MaxAttempts = cfg.NotifyMaxAttempts
+	return notifier, nil
+}
+
+// startHTTPServer serves health, status, metrics and the admin API. Probes are always
+// public, status and metrics require the read role, and the admin actions and pprof,
+// unless it is served on PPROF_ADDR, the admin role. With leader election, every
+// replica serves them, but only the leader accepts admin actions.
+func startHTTPServer(cfg *config.Config, targets []*target, authn *atomic.Pointer[auth.Authenticator], elector *leader.Elector, cancel context.CancelFunc) (*echo.Echo, error) {
 	e := echo.New()
 	e.HideBanner = true
+	read, admin := requireRole(authn, auth.RoleRead), requireRole(authn, auth.RoleAdmin)
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// --- Routes ---
 	e.GET("/healthz", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	})
 	e.GET("/readyz", func(c echo.Context) error {
-		if err := checker.Ready(c.Request().Context()); err != nil {
-			return c.JSON(http.StatusServiceUnavailable, map[string]string{
-				"status": "unready",
-				"error":  err.Error(),
-			})
+		if len(targets) == 1 {
+			return respondReadiness(c, checkReadiness(c.Request().Context(), targets[0]))
 		}
-		return c.JSON(http.StatusOK, map[string]string{"status": "ready"})
-	})
-	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
 
-	// pprof routes
-	pprofGroup := e.Group("/debug/pprof")
-	pprofGroup.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
-	pprofGroup.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
-	pprofGroup.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
-	pprofGroup.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
-	pprofGroup.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+		results := make([]readiness, len(targets))
+		var wg sync.WaitGroup
+		for i, t := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = checkReadiness(c.Request().Context(), t)
+			}()
+		}
+		wg.Wait()
+
+		statuses := make(map[string]readiness, len(targets))
+		for i, t := range targets {
+			statuses[t.name] = results[i]
+		}
+		return respondReadiness(c, overallReadiness(statuses))
+	})
+	e.GET("/readyz/:target", func(c echo.Context) error {
+		t := findTarget(targets, c.Param("target"))
+		if t == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "unknown target"})
+		}
+		return respondReadiness(c, checkReadiness(c.Request().Context(), t))
+	})
+	e.GET("/status", func(c echo.Context) error {
+		statuses := make([]targetStatus, len(targets))
+		for i, t := range targets {
+			statuses[i] = targetStatus{Status: t.updater.Status(), Leader: currentLeader(elector)}
+		}
+		return c.JSON(http.StatusOK, statuses)
+	}, read)
+	e.GET("/status/:target", func(c echo.Context) error {
+		t := findTarget(targets, c.Param("target"))
+		if t == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "unknown target"})
+		}
+		return c.JSON(http.StatusOK, targetStatus{Status: t.updater.Status(), Leader: currentLeader(elector)})
+	}, read)
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), read)
+	registerAPI(e, targets, authn, elector)
+	if cfg.PprofAddr == "" {
+		registerPprof(e.Group("/debug/pprof", admin))
+	}
 
 	go func() {
 		addr := fmt.Sprintf(":%s", cfg.HTTPPort)
-		xlog.Info("starting HTTP server for metrics, health, and pprof", "port", cfg.HTTPPort)
-		if err := e.Start(addr); err != nil && err != http.ErrServerClosed {
+		xlog.Info("starting HTTP server for metrics, health, and pprof", "port", cfg.HTTPPort, "tls", tlsConfig != nil)
+		var err error
+		if tlsConfig != nil {
+			e.TLSServer.Addr = addr
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(addr)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			xlog.Error("http server failed", "err", err)
 			cancel()
 		}
 	}()
 
+	return e, nil
+}
+
+// newTLSConfig returns the TLS configuration of the HTTP server, or nil to serve plain
+// HTTP. With a client CA, client certificates are verified when presented but not
+// required, so probes and token-authenticated clients keep working.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.HTTPTLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.HTTPTLSCertFile, cfg.HTTPTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.HTTPClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.HTTPClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s has no certificates", cfg.HTTPClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// startPprofServer serves pprof without authentication on addr, which config
+// validation restricts to a loopback address.
+func startPprofServer(addr string, cancel context.CancelFunc) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	registerPprof(e.Group("/debug/pprof"))
+
+	go func() {
+		xlog.Info("starting pprof server", "addr", addr)
+		if err := e.Start(addr); err != nil && err != http.ErrServerClosed {
+			xlog.Error("pprof server failed", "err", err)
+			cancel()
+		}
+	}()
 	return e
 }
+
+func registerPprof(g *echo.Group) {
+	g.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+}
+
+func shutdownHTTPServer(e *echo.Echo) {
+	xlog.Info("shutting down http server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		xlog.Error("http server shutdown failed", "err", err)
+	}
+}
+
+// targetStatus is the body of /status for one target. Leader is the identity of the
+// replica running the updaters with leader election, the status of the others is not
+// updated.
+type targetStatus struct {
+	updater.Status
+	Leader string `json:"leader,omitempty"`
+}
+
+// Readiness states reported by /readyz.
+const (
+	statusReady    = "ready"
+	statusDegraded = "degraded"
+	statusUnready  = "unready"
+)
+
+// readiness is the body of /readyz. With several targets, Targets holds the status of
+// each one, see overallReadiness.
+type readiness struct {
+	Status  string               `json:"status"`
+	Error   string               `json:"error,omitempty"`
+	Targets map[string]readiness `json:"targets,omitempty"`
+}
+
+// checkReadiness runs the readiness checks of t.
+func checkReadiness(ctx context.Context, t *target) readiness {
+	if err := t.checker.Ready(ctx); err != nil {
+		return readiness{Status: statusUnready, Error: err.Error()}
+	}
+	return readiness{Status: statusReady}
+}
+
+// overallReadiness combines the readiness of several targets. It is only unready when
+// every target is, so one failing chain does not take the status and admin API of the
+// others out of service; it is degraded when some are. /readyz/<target> tells each
+// target apart.
+func overallReadiness(targets map[string]readiness) readiness {
+	overall := readiness{Status: statusUnready, Targets: targets}
+	unready := 0
+	for _, r := range targets {
+		if r.Status != statusReady {
+			unready++
+		}
+	}
+	switch {
+	case unready == 0:
+		overall.Status = statusReady
+	case unready < len(targets):
+		overall.Status = statusDegraded
+	}
+	return overall
+}
+
+func respondReadiness(c echo.Context, r readiness) error {
+	if r.Status == statusUnready {
+		return c.JSON(http.StatusServiceUnavailable, r)
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// findTarget returns the target named name, or nil if there is none.
+func findTarget(targets []*target, name string) *target {
+	for _, t := range targets {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
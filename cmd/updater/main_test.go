@@ -0,0 +1,23 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("overallReadiness", func() {
+	ready := readiness{Status: statusReady}
+	unready := readiness{Status: statusUnready, Error: "cosmos connection failed"}
+
+	DescribeTable("should only be unready when every target is",
+		func(testnet, mainnet readiness, status string) {
+			overall := overallReadiness(map[string]readiness{"testnet": testnet, "mainnet": mainnet})
+			Expect(overall.Status).To(Equal(status))
+			Expect(overall.Targets).To(HaveKeyWithValue("testnet", testnet))
+			Expect(overall.Targets).To(HaveKeyWithValue("mainnet", mainnet))
+		},
+		Entry("all ready", ready, ready, statusReady),
+		Entry("one unready", ready, unready, statusDegraded),
+		Entry("all unready", unready, unready, statusUnready),
+	)
+})
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/dockerhub"
+	"github.com/gopher-lab/gopher-updater/health"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/notify"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/gopher-lab/gopher-updater/registry"
+	"github.com/gopher-lab/gopher-updater/state"
+	"github.com/gopher-lab/gopher-updater/updater"
+)
+
+// target is everything that runs for one configured chain and environment.
+type target struct {
+	name    string
+	checker *health.Checker
+	updater *updater.Updater
+
+	mu    sync.Mutex
+	cfg   *config.Config
+	store state.Store
+	// notifiers holds every notifier the target used, so pending deliveries
+	// of notifiers replaced by a reload are flushed on shutdown too.
+	notifiers []*notify.Notifier
+}
+
+// clients are what a target is built from. They are rebuilt when the configuration is reloaded.
+type clients struct {
+	cosmos   cosmos.ClientInterface
+	block    cosmos.BlockClient
+	registry dockerhub.ClientInterface
+	store    state.Store
+	notifier *notify.Notifier
+}
+
+// newClients builds the clients for cfg.
+func newClients(cfg *config.Config, httpClient *http.Client) (*clients, error) {
+	c := &clients{}
+	c.cosmos, c.block = newCosmosClient(cfg, httpClient)
+	if cfg.RegistryURL != "" {
+		c.registry = registry.NewClient(cfg.RegistryURL, cfg.RegistryUser, cfg.RegistryPassword, httpClient)
+	} else {
+		c.registry = dockerhub.NewClient(cfg.DockerHubUser, cfg.DockerHubPassword, httpClient)
+	}
+
+	var err error
+	c.store, err = newStateStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state store: %w", err)
+	}
+	c.notifier, err = newNotifier(cfg, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notifier: %w", err)
+	}
+	return c, nil
+}
+
+// options returns the updater options for the state store and notifier.
+func (c *clients) options() []updater.Option {
+	var opts []updater.Option
+	if c.store != nil {
+		opts = append(opts, updater.WithStateStore(c.store))
+	}
+	if c.notifier != nil {
+		opts = append(opts, updater.WithNotifier(c.notifier))
+	}
+	return opts
+}
+
+// newTarget builds the clients, readiness checker and updater of one target.
+func newTarget(cfg *config.Config, httpClient *http.Client) (*target, error) {
+	c, err := newClients(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	updaterOpts := c.options()
+	if cfg.SubscribeBlocks {
+		subscriber, err := cosmos.NewBlockSubscriber(cfg.CometRPCURLs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create block subscriber: %w", err)
+		}
+		updaterOpts = append(updaterOpts, updater.WithBlockSubscriber(subscriber))
+	}
+
+	t := &target{
+		name:    cfg.Target,
+		checker: health.NewChecker(c.block, c.registry, cfg.Repos()[0].Path),
+		updater: updater.New(c.cosmos, c.registry, cfg, updaterOpts...),
+		cfg:     cfg,
+		store:   c.store,
+	}
+	if c.notifier != nil {
+		t.notifiers = append(t.notifiers, c.notifier)
+	}
+	return t, nil
+}
+
+// config returns the current configuration of t.
+func (t *target) config() *config.Config {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cfg
+}
+
+// stateSettings select the state store. While they do not change, a reload keeps the
+// store, so the updater does not restore its runtime state from it again.
+var stateSettings = []string{"STATE_BACKEND", "STATE_FILE", "STATE_CONFIGMAP", "STATE_NAMESPACE"}
+
+// reload swaps cfg and the clients built from it into the checker and updater of t.
+func (t *target) reload(ctx context.Context, cfg *config.Config, c *clients) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.cfg.Changed(cfg, stateSettings...)) == 0 {
+		c.store = t.store
+	}
+	t.cfg, t.store = cfg, c.store
+	if c.notifier != nil {
+		t.notifiers = append(t.notifiers, c.notifier)
+	}
+	t.checker.Reload(c.block, c.registry, cfg.Repos()[0].Path)
+	t.updater.Reload(ctx, c.cosmos, c.registry, cfg, c.options()...)
+}
+
+// closeNotifiers waits for the notifications of t being delivered, without waiting
+// to retry those that failed.
+func (t *target) closeNotifiers() {
+	t.mu.Lock()
+	notifiers := append([]*notify.Notifier(nil), t.notifiers...)
+	t.mu.Unlock()
+
+	for _, notifier := range notifiers {
+		notifier.Close()
+	}
+}
+
+// runTarget runs the updater of t until ctx is done. An updater that stops or panics is
+// restarted after a poll interval, without affecting the updaters of other targets.
+func runTarget(ctx context.Context, t *target) {
+	for {
+		err := runUpdater(ctx, t.updater)
+		if ctx.Err() != nil {
+			return
+		}
+		interval := t.config().PollInterval
+		xlog.Error("updater failed, restarting", "target", t.name, "in", interval, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runUpdater runs upd, turning a panic into an error.
+func runUpdater(ctx context.Context, upd *updater.Updater) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("updater panicked: %v", r)
+		}
+	}()
+	return upd.Run(ctx)
+}
+
+// watchReloads reloads the configuration on SIGHUP and, if a configuration file is
+// used, whenever its content changes, until ctx is done.
+func watchReloads(ctx context.Context, configFile string, interval time.Duration, targets []*target, authn *atomic.Pointer[auth.Authenticator], httpClient *http.Client) {
+	requests := make(chan struct{}, 1)
+	request := func() {
+		select {
+		case requests <- struct{}{}:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				xlog.Info("reload signal received")
+				request()
+			}
+		}
+	}()
+	if configFile != "" && interval > 0 {
+		go config.WatchFile(ctx, configFile, interval, request)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-requests:
+			err := reloadTargets(ctx, configFile, targets, authn, httpClient)
+			metrics.ConfigReloads.WithLabelValues(metrics.Result(err)).Inc()
+			if err != nil {
+				xlog.Error("failed to reload config, keeping the current one", "err", err)
+				continue
+			}
+			metrics.LastSuccessfulConfigReload.SetToCurrentTime()
+			xlog.Info("reloaded config")
+		}
+	}
+}
+
+// reloadTargets loads and validates the configuration again and swaps it into every
+// target and into the authenticator. Nothing is swapped unless everything could be
+// rebuilt. Adding, removing or renaming targets, or changing a setting only read at
+// startup, needs a restart.
+func reloadTargets(ctx context.Context, configFile string, targets []*target, authn *atomic.Pointer[auth.Authenticator], httpClient *http.Client) error {
+	cfgs, err := config.NewTargets(ctx, configFile)
+	if err != nil {
+		return err
+	}
+	if len(cfgs) != len(targets) {
+		return errors.New("the list of targets changed, restart to apply it")
+	}
+
+	built := make([]*clients, len(cfgs))
+	for i, cfg := range cfgs {
+		if cfg.Target != targets[i].name {
+			return errors.New("the list of targets changed, restart to apply it")
+		}
+		if changed := restartOnlyChanges(targets[i].config(), cfg); len(changed) > 0 {
+			return fmt.Errorf("target %s: changing %s needs a restart", cfg.Target, strings.Join(changed, ", "))
+		}
+		built[i], err = newClients(cfg, httpClient)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", cfg.Target, err)
+		}
+	}
+	authenticator, err := auth.New(cfgs[0].Auth())
+	if err != nil {
+		return fmt.Errorf("failed to set up authentication: %w", err)
+	}
+
+	for i, t := range targets {
+		t.reload(ctx, cfgs[i], built[i])
+	}
+	authn.Store(authenticator)
+	return nil
+}
+
+// restartOnlyChanges returns the settings only read at startup that differ between the
+// running configuration and the reloaded one. The block subscription is only set up at
+// startup, so its endpoints are too when it is enabled.
+func restartOnlyChanges(running, reloaded *config.Config) []string {
+	names := config.RestartOnly
+	if running.SubscribeBlocks {
+		names = append(slices.Clip(names), "COMETBFT_RPC_URL")
+	}
+	return running.Changed(reloaded, names...)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/gopher-lab/gopher-updater/config"
+)
+
+var _ = Describe("reloadTargets", func() {
+	var (
+		ctx     context.Context
+		targets []*target
+		authn   atomic.Pointer[auth.Authenticator]
+	)
+
+	load := func() {
+		cfgs, err := config.NewTargets(ctx, "")
+		Expect(err).NotTo(HaveOccurred())
+		targets = nil
+		for _, cfg := range cfgs {
+			t, err := newTarget(cfg, http.DefaultClient)
+			Expect(err).NotTo(HaveOccurred())
+			targets = append(targets, t)
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		authn.Store(nil)
+		GinkgoT().Setenv("TARGETS", "")
+		GinkgoT().Setenv("REGISTRY_URL", "https://registry.example.com")
+		GinkgoT().Setenv("REPO_PATH", "my/repo")
+		GinkgoT().Setenv("TARGET_PREFIX", "mainnet-")
+		GinkgoT().Setenv("HTTP_PORT", "8080")
+		GinkgoT().Setenv("SUBSCRIBE_BLOCKS", "true")
+		GinkgoT().Setenv("COMETBFT_RPC_URL", "http://localhost:26657")
+		load()
+	})
+
+	It("should apply the reloaded configuration", func() {
+		GinkgoT().Setenv("TARGET_PREFIX", "testnet-")
+
+		Expect(reloadTargets(ctx, "", targets, &authn, http.DefaultClient)).To(Succeed())
+		Expect(targets[0].config().TargetPrefix).To(Equal("testnet-"))
+		Expect(authn.Load()).NotTo(BeNil())
+	})
+
+	It("should refuse to reload a setting only read at startup", func() {
+		GinkgoT().Setenv("TARGET_PREFIX", "testnet-")
+		GinkgoT().Setenv("HTTP_PORT", "9090")
+
+		err := reloadTargets(ctx, "", targets, &authn, http.DefaultClient)
+		Expect(err).To(MatchError(ContainSubstring("changing HTTP_PORT needs a restart")))
+		Expect(targets[0].config().TargetPrefix).To(Equal("mainnet-"))
+		Expect(authn.Load()).To(BeNil())
+	})
+
+	It("should refuse to move the block subscription to other endpoints", func() {
+		GinkgoT().Setenv("COMETBFT_RPC_URL", "http://other:26657")
+
+		err := reloadTargets(ctx, "", targets, &authn, http.DefaultClient)
+		Expect(err).To(MatchError(ContainSubstring("COMETBFT_RPC_URL")))
+	})
+
+	It("should reload the CometBFT endpoints when blocks are not subscribed to", func() {
+		GinkgoT().Setenv("SUBSCRIBE_BLOCKS", "false")
+		load()
+		GinkgoT().Setenv("COMETBFT_RPC_URL", "http://other:26657")
+
+		Expect(reloadTargets(ctx, "", targets, &authn, http.DefaultClient)).To(Succeed())
+		Expect(targets[0].config().CometRPCURLs).To(Equal([]string{"http://other:26657"}))
+	})
+
+	It("should keep the state store unless its settings changed", func() {
+		dir := GinkgoT().TempDir()
+		GinkgoT().Setenv("STATE_BACKEND", "file")
+		GinkgoT().Setenv("STATE_FILE", filepath.Join(dir, "state.json"))
+		load()
+		store := targets[0].store
+
+		GinkgoT().Setenv("TARGET_PREFIX", "testnet-")
+		Expect(reloadTargets(ctx, "", targets, &authn, http.DefaultClient)).To(Succeed())
+		Expect(targets[0].store).To(BeIdenticalTo(store))
+
+		GinkgoT().Setenv("STATE_FILE", filepath.Join(dir, "other.json"))
+		Expect(reloadTargets(ctx, "", targets, &authn, http.DefaultClient)).To(Succeed())
+		Expect(targets[0].store).NotTo(BeIdenticalTo(store))
+	})
+
+	It("should refuse a change to the list of targets", func() {
+		GinkgoT().Setenv("TARGETS", "testnet,mainnet")
+
+		err := reloadTargets(ctx, "", targets, &authn, http.DefaultClient)
+		Expect(err).To(MatchError(ContainSubstring("the list of targets changed")))
+	})
+})
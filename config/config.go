@@ -2,32 +2,386 @@ package config
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/gopher-lab/gopher-updater/auth"
 	"github.com/sethvargo/go-envconfig"
 )
 
+// Block backends that can be selected with BLOCK_BACKEND.
+const (
+	BlockBackendREST     = "rest"
+	BlockBackendCometBFT = "cometbft"
+)
+
+// State backends that can be selected with STATE_BACKEND. An empty backend disables the state store.
+const (
+	StateBackendFile      = "file"
+	StateBackendConfigMap = "configmap"
+)
+
+// Lock backends that can be selected with LEADER_ELECTION. An empty backend disables
+// leader election, so every replica promotes upgrades.
+const (
+	LeaderElectionLease = "lease"
+	LeaderElectionFile  = "file"
+)
+
+// Catch-up policies that can be selected with CATCH_UP_POLICY. They decide what happens
+// when several upgrade heights have been reached, e.g. after an outage.
+const (
+	CatchUpOldest = "oldest"
+	CatchUpLatest = "latest"
+	CatchUpAll    = "all"
+)
+
 // Config holds the application configuration.
 type Config struct {
-	RPCURL            string        `env:"RPC_URL,default=http://localhost:1317"`
-	DockerHubUser     string        `env:"DOCKERHUB_USER,required"`
-	DockerHubPassword string        `env:"DOCKERHUB_PASSWORD,required"`
-	RepoPath          string        `env:"REPO_PATH,required"`
-	SourcePrefix      string        `env:"SOURCE_PREFIX,default=release-"`
-	TargetPrefix      string        `env:"TARGET_PREFIX,required"`
-	PollInterval      time.Duration `env:"POLL_INTERVAL,default=1m"`
+	// Target names the chain and environment this configuration belongs to, see NewTargets.
+	// It is empty when only a single target is configured.
+	Target string `yaml:"-"`
+
+	RPCURLs             []string      `env:"RPC_URL,default=http://localhost:1317" yaml:"rpc_url"`
+	RPCCooldown         time.Duration `env:"RPC_COOLDOWN,default=30s" yaml:"rpc_cooldown"`
+	RPCQuorum           int           `env:"RPC_QUORUM,default=0" yaml:"rpc_quorum"`
+	BlockBackend        string        `env:"BLOCK_BACKEND,default=rest" yaml:"block_backend"`
+	CometRPCURLs        []string      `env:"COMETBFT_RPC_URL,default=http://localhost:26657" yaml:"cometbft_rpc_url"`
+	SubscribeBlocks     bool          `env:"SUBSCRIBE_BLOCKS,default=false" yaml:"subscribe_blocks"`
+	DockerHubUser       string        `env:"DOCKERHUB_USER" yaml:"dockerhub_user"`
+	DockerHubPassword   string        `env:"DOCKERHUB_PASSWORD" yaml:"dockerhub_password"`
+	RegistryURL         string        `env:"REGISTRY_URL" yaml:"registry_url"`
+	RegistryUser        string        `env:"REGISTRY_USER" yaml:"registry_user"`
+	RegistryPassword    string        `env:"REGISTRY_PASSWORD" yaml:"registry_password"`
+	RepoPath            string        `env:"REPO_PATH" yaml:"repo_path"`
+	Repositories        Repositories  `env:"REPOSITORIES" yaml:"repositories"`
+	SourcePrefix        string        `env:"SOURCE_PREFIX,default=release-" yaml:"source_prefix"`
+	TargetPrefix        string        `env:"TARGET_PREFIX" yaml:"target_prefix"`
+	SourceTagTemplate   string        `env:"SOURCE_TAG_TEMPLATE" yaml:"source_tag_template"`
+	TargetTagTemplate   string        `env:"TARGET_TAG_TEMPLATE" yaml:"target_tag_template"`
+	SourceImageInfoPath string        `env:"SOURCE_IMAGE_INFO_PATH" yaml:"source_image_info_path"`
+	PollInterval        time.Duration `env:"POLL_INTERVAL,default=1m" yaml:"poll_interval"`
+	CatchUpPolicy       string        `env:"CATCH_UP_POLICY,default=oldest" yaml:"catch_up_policy"`
+	PreflightWarnBlocks int64         `env:"PREFLIGHT_WARN_BLOCKS,default=1000" yaml:"preflight_warn_blocks"`
+	DryRun              bool          `env:"DRY_RUN,default=false" yaml:"dry_run"`
+	RetagAttempts       int           `env:"RETAG_ATTEMPTS,default=3" yaml:"retag_attempts"`
+	RetagBackoff        time.Duration `env:"RETAG_BACKOFF,default=2s" yaml:"retag_backoff"`
+
+	RequireApproval       bool          `env:"REQUIRE_APPROVAL,default=false" yaml:"require_approval"`
+	ApprovalTimeout       time.Duration `env:"APPROVAL_TIMEOUT,default=0s" yaml:"approval_timeout"`
+	ApprovalDir           string        `env:"APPROVAL_DIR" yaml:"approval_dir"`
+	ApprovalWebhookSecret string        `env:"APPROVAL_WEBHOOK_SECRET" yaml:"approval_webhook_secret"`
+
+	AdaptivePolling bool          `env:"ADAPTIVE_POLLING,default=false" yaml:"adaptive_polling"`
+	MinPollInterval time.Duration `env:"MIN_POLL_INTERVAL,default=1s" yaml:"min_poll_interval"`
+	MaxPollInterval time.Duration `env:"MAX_POLL_INTERVAL,default=15m" yaml:"max_poll_interval"`
+	BlockTimeWindow int           `env:"BLOCK_TIME_WINDOW,default=100" yaml:"block_time_window"`
 
-	HTTPMaxIdleConns        int    `env:"HTTP_MAX_IDLE_CONNS,default=100"`
-	HTTPMaxIdleConnsPerHost int    `env:"HTTP_MAX_IDLE_CONNS_PER_HOST,default=10"`
-	HTTPMaxConnsPerHost     int    `env:"HTTP_MAX_CONNS_PER_HOST,default=10"`
-	HTTPPort                string `env:"HTTP_PORT,default=8080"`
+	StateBackend   string `env:"STATE_BACKEND" yaml:"state_backend"`
+	StateFile      string `env:"STATE_FILE,default=/var/lib/gopher-updater/state.json" yaml:"state_file"`
+	StateConfigMap string `env:"STATE_CONFIGMAP,default=gopher-updater-state" yaml:"state_configmap"`
+	StateNamespace string `env:"STATE_NAMESPACE" yaml:"state_namespace"`
+
+	LeaderElection              string        `env:"LEADER_ELECTION" yaml:"leader_election"`
+	LeaderElectionIdentity      string        `env:"LEADER_ELECTION_IDENTITY" yaml:"leader_election_identity"`
+	LeaderElectionLeaseName     string        `env:"LEADER_ELECTION_LEASE_NAME,default=gopher-updater" yaml:"leader_election_lease_name"`
+	LeaderElectionNamespace     string        `env:"LEADER_ELECTION_NAMESPACE" yaml:"leader_election_namespace"`
+	LeaderElectionLockFile      string        `env:"LEADER_ELECTION_LOCK_FILE,default=/var/lib/gopher-updater/leader.lock" yaml:"leader_election_lock_file"`
+	LeaderElectionLeaseDuration time.Duration `env:"LEADER_ELECTION_LEASE_DURATION,default=15s" yaml:"leader_election_lease_duration"`
+
+	NotifyWebhookURL        string `env:"NOTIFY_WEBHOOK_URL" yaml:"notify_webhook_url"`
+	NotifySlackWebhookURL   string `env:"NOTIFY_SLACK_WEBHOOK_URL" yaml:"notify_slack_webhook_url"`
+	NotifyDiscordWebhookURL string `env:"NOTIFY_DISCORD_WEBHOOK_URL" yaml:"notify_discord_webhook_url"`
+	NotifyTelegramBotToken  string `env:"NOTIFY_TELEGRAM_BOT_TOKEN" yaml:"notify_telegram_bot_token"`
+	NotifyTelegramChatID    string `env:"NOTIFY_TELEGRAM_CHAT_ID" yaml:"notify_telegram_chat_id"`
+	NotifyTemplateDir       string `env:"NOTIFY_TEMPLATE_DIR" yaml:"notify_template_dir"`
+	NotifyMaxAttempts       int    `env:"NOTIFY_MAX_ATTEMPTS,default=3" yaml:"notify_max_attempts"`
+
+	HTTPMaxIdleConns        int    `env:"HTTP_MAX_IDLE_CONNS,default=100" yaml:"http_max_idle_conns"`
+	HTTPMaxIdleConnsPerHost int    `env:"HTTP_MAX_IDLE_CONNS_PER_HOST,default=10" yaml:"http_max_idle_conns_per_host"`
+	HTTPMaxConnsPerHost     int    `env:"HTTP_MAX_CONNS_PER_HOST,default=10" yaml:"http_max_conns_per_host"`
+	HTTPPort                string `env:"HTTP_PORT,default=8080" yaml:"http_port"`
+	HTTPTLSCertFile         string `env:"HTTP_TLS_CERT_FILE" yaml:"http_tls_cert_file"`
+	HTTPTLSKeyFile          string `env:"HTTP_TLS_KEY_FILE" yaml:"http_tls_key_file"`
+	HTTPClientCAFile        string `env:"HTTP_CLIENT_CA_FILE" yaml:"http_client_ca_file"`
+	PprofAddr               string `env:"PPROF_ADDR" yaml:"pprof_addr"`
+
+	AdminToken         string            `env:"ADMIN_TOKEN" yaml:"admin_token"`
+	AuthTokens         map[string]string `env:"AUTH_TOKENS" yaml:"auth_tokens"`
+	AuthClientCerts    map[string]string `env:"AUTH_CLIENT_CERTS" yaml:"auth_client_certs"`
+	AuthAnonymousRole  string            `env:"AUTH_ANONYMOUS_ROLE,default=read" yaml:"auth_anonymous_role"`
+	AuthOIDCJWKSFile   string            `env:"AUTH_OIDC_JWKS_FILE" yaml:"auth_oidc_jwks_file"`
+	AuthOIDCIssuer     string            `env:"AUTH_OIDC_ISSUER" yaml:"auth_oidc_issuer"`
+	AuthOIDCAudience   string            `env:"AUTH_OIDC_AUDIENCE" yaml:"auth_oidc_audience"`
+	AuthOIDCRolesClaim string            `env:"AUTH_OIDC_ROLES_CLAIM,default=roles" yaml:"auth_oidc_roles_claim"`
+
+	ConfigWatchInterval time.Duration `env:"CONFIG_WATCH_INTERVAL,default=10s" yaml:"config_watch_interval"`
 }
 
 // New loads the configuration from environment variables.
 func New(ctx context.Context) (*Config, error) {
+	cfg, err := defaults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.applyEnv(ctx, envconfig.OsLookuper()); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaults returns the configuration with only its default values set.
+func defaults(ctx context.Context) (*Config, error) {
 	var cfg Config
-	if err := envconfig.Process(ctx, &cfg); err != nil {
+	if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: &cfg, Lookuper: envconfig.MapLookuper(nil)}); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
+
+// applyEnv overrides the fields whose variable is set to a non-empty value in lookuper
+// and leaves the others alone, so variables take precedence over the configuration file.
+func (c *Config) applyEnv(ctx context.Context, lookuper envconfig.Lookuper) error {
+	var env Config
+	if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: &env, Lookuper: lookuper}); err != nil {
+		return err
+	}
+
+	dst, src := reflect.ValueOf(c).Elem(), reflect.ValueOf(&env).Elem()
+	for i := range dst.NumField() {
+		key, _, _ := strings.Cut(dst.Type().Field(i).Tag.Get("env"), ",")
+		if key == "" {
+			continue
+		}
+		if value, ok := lookuper.Lookup(key); ok && value != "" {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return nil
+}
+
+// RestartOnly lists, by environment variable, the settings that are only read at
+// startup, so changing them needs a restart rather than a reload.
+var RestartOnly = []string{
+	"SUBSCRIBE_BLOCKS",
+	"LEADER_ELECTION",
+	"LEADER_ELECTION_IDENTITY",
+	"LEADER_ELECTION_LEASE_NAME",
+	"LEADER_ELECTION_NAMESPACE",
+	"LEADER_ELECTION_LOCK_FILE",
+	"LEADER_ELECTION_LEASE_DURATION",
+	"HTTP_MAX_IDLE_CONNS",
+	"HTTP_MAX_IDLE_CONNS_PER_HOST",
+	"HTTP_MAX_CONNS_PER_HOST",
+	"HTTP_PORT",
+	"HTTP_TLS_CERT_FILE",
+	"HTTP_TLS_KEY_FILE",
+	"HTTP_CLIENT_CA_FILE",
+	"PPROF_ADDR",
+	"CONFIG_WATCH_INTERVAL",
+}
+
+// Changed returns, among the settings named by their environment variable, those
+// whose value differs between c and other.
+func (c *Config) Changed(other *Config, names ...string) []string {
+	a, b := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	var changed []string
+	for i := range a.NumField() {
+		key, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("env"), ",")
+		if key != "" && slices.Contains(names, key) && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// validate checks the configuration. Errors name settings by their environment
+// variable; the matching configuration file key is the same name in lower case.
+func (c *Config) validate() error {
+	if c.RegistryURL == "" && (c.DockerHubUser == "" || c.DockerHubPassword == "") {
+		return fmt.Errorf("DOCKERHUB_USER and DOCKERHUB_PASSWORD are required unless REGISTRY_URL is set")
+	}
+
+	switch c.BlockBackend {
+	case BlockBackendREST, BlockBackendCometBFT:
+	default:
+		return fmt.Errorf("invalid BLOCK_BACKEND %q, must be %q or %q", c.BlockBackend, BlockBackendREST, BlockBackendCometBFT)
+	}
+	switch c.CatchUpPolicy {
+	case CatchUpOldest, CatchUpLatest, CatchUpAll:
+	default:
+		return fmt.Errorf("invalid CATCH_UP_POLICY %q, must be %q, %q or %q", c.CatchUpPolicy, CatchUpOldest, CatchUpLatest, CatchUpAll)
+	}
+
+	switch c.StateBackend {
+	case "", StateBackendFile, StateBackendConfigMap:
+	default:
+		return fmt.Errorf("invalid STATE_BACKEND %q, must be empty, %q or %q", c.StateBackend, StateBackendFile, StateBackendConfigMap)
+	}
+	switch c.LeaderElection {
+	case "", LeaderElectionLease, LeaderElectionFile:
+	default:
+		return fmt.Errorf("invalid LEADER_ELECTION %q, must be empty, %q or %q", c.LeaderElection, LeaderElectionLease, LeaderElectionFile)
+	}
+	if c.LeaderElection != "" && c.LeaderElectionLeaseDuration < time.Second {
+		return fmt.Errorf("LEADER_ELECTION_LEASE_DURATION must be at least 1s, got %s", c.LeaderElectionLeaseDuration)
+	}
+
+	if len(c.RPCURLs) == 0 {
+		return fmt.Errorf("RPC_URL must list at least one endpoint")
+	}
+	if c.BlockBackend == BlockBackendCometBFT && len(c.CometRPCURLs) != len(c.RPCURLs) {
+		return fmt.Errorf("COMETBFT_RPC_URL must list one endpoint per RPC_URL endpoint, got %d and %d", len(c.CometRPCURLs), len(c.RPCURLs))
+	}
+	if c.RepoPath == "" && len(c.Repositories) == 0 {
+		return fmt.Errorf("REPO_PATH or REPOSITORIES is required")
+	}
+	if err := validateRepos(c.Repos()); err != nil {
+		return err
+	}
+	if (c.NotifyTelegramBotToken == "") != (c.NotifyTelegramChatID == "") {
+		return fmt.Errorf("NOTIFY_TELEGRAM_BOT_TOKEN and NOTIFY_TELEGRAM_CHAT_ID must be set together")
+	}
+	if c.RetagAttempts < 1 {
+		return fmt.Errorf("RETAG_ATTEMPTS must be at least 1")
+	}
+	if c.NotifyMaxAttempts < 1 {
+		return fmt.Errorf("NOTIFY_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.RPCQuorum > len(c.RPCURLs) {
+		return fmt.Errorf("RPC_QUORUM %d exceeds the number of RPC_URL endpoints (%d)", c.RPCQuorum, len(c.RPCURLs))
+	}
+
+	if err := validateURLs("RPC_URL", c.RPCURLs, "http", "https"); err != nil {
+		return err
+	}
+	if c.BlockBackend == BlockBackendCometBFT || c.SubscribeBlocks {
+		if err := validateURLs("COMETBFT_RPC_URL", c.CometRPCURLs, "http", "https", "ws", "wss"); err != nil {
+			return err
+		}
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"REGISTRY_URL", c.RegistryURL},
+		{"NOTIFY_WEBHOOK_URL", c.NotifyWebhookURL},
+		{"NOTIFY_SLACK_WEBHOOK_URL", c.NotifySlackWebhookURL},
+		{"NOTIFY_DISCORD_WEBHOOK_URL", c.NotifyDiscordWebhookURL},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		if err := validateURLs(setting.name, []string{setting.value}, "http", "https"); err != nil {
+			return err
+		}
+	}
+	if c.ApprovalTimeout < 0 {
+		return fmt.Errorf("APPROVAL_TIMEOUT must not be negative, got %s", c.ApprovalTimeout)
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("POLL_INTERVAL must be positive, got %s", c.PollInterval)
+	}
+	if c.AdaptivePolling {
+		if c.MinPollInterval <= 0 {
+			return fmt.Errorf("MIN_POLL_INTERVAL must be positive, got %s", c.MinPollInterval)
+		}
+		if c.MaxPollInterval < c.MinPollInterval {
+			return fmt.Errorf("MAX_POLL_INTERVAL %s is shorter than MIN_POLL_INTERVAL %s", c.MaxPollInterval, c.MinPollInterval)
+		}
+		if c.BlockTimeWindow < 1 {
+			return fmt.Errorf("BLOCK_TIME_WINDOW must be at least 1")
+		}
+	}
+
+	return c.validateHTTP()
+}
+
+// validateHTTP checks the settings of the HTTP server and of its authentication.
+func (c *Config) validateHTTP() error {
+	if (c.HTTPTLSCertFile == "") != (c.HTTPTLSKeyFile == "") {
+		return fmt.Errorf("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	if c.HTTPClientCAFile != "" && c.HTTPTLSCertFile == "" {
+		return fmt.Errorf("HTTP_CLIENT_CA_FILE requires HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE")
+	}
+	if len(c.AuthClientCerts) > 0 && c.HTTPClientCAFile == "" {
+		return fmt.Errorf("AUTH_CLIENT_CERTS requires HTTP_CLIENT_CA_FILE")
+	}
+	if c.PprofAddr != "" {
+		host, _, err := net.SplitHostPort(c.PprofAddr)
+		if ip := net.ParseIP(host); err != nil || (host != "localhost" && (ip == nil || !ip.IsLoopback())) {
+			return fmt.Errorf("invalid PPROF_ADDR %q, must be a loopback address and port, e.g. 127.0.0.1:6060", c.PprofAddr)
+		}
+	}
+
+	for _, setting := range []struct {
+		name  string
+		roles map[string]string
+	}{
+		{"AUTH_TOKENS", c.AuthTokens},
+		{"AUTH_CLIENT_CERTS", c.AuthClientCerts},
+	} {
+		for key, role := range setting.roles {
+			if key == "" {
+				return fmt.Errorf("%s has an empty entry", setting.name)
+			}
+			if _, err := auth.ParseRole(role); err != nil {
+				return fmt.Errorf("invalid %s: %w", setting.name, err)
+			}
+		}
+	}
+	switch auth.Role(c.AuthAnonymousRole) {
+	case auth.RoleNone, auth.RoleRead:
+	default:
+		return fmt.Errorf("invalid AUTH_ANONYMOUS_ROLE %q, must be %q or %q", c.AuthAnonymousRole, auth.RoleNone, auth.RoleRead)
+	}
+	if c.AuthOIDCJWKSFile != "" && (c.AuthOIDCIssuer == "" || c.AuthOIDCAudience == "") {
+		return fmt.Errorf("AUTH_OIDC_ISSUER and AUTH_OIDC_AUDIENCE are required with AUTH_OIDC_JWKS_FILE")
+	}
+	return nil
+}
+
+// Auth returns the authentication settings of the HTTP server. ADMIN_TOKEN is an
+// additional token with the admin role.
+func (c *Config) Auth() auth.Config {
+	cfg := auth.Config{
+		Tokens:      make(map[string]auth.Role, len(c.AuthTokens)+1),
+		ClientCerts: make(map[string]auth.Role, len(c.AuthClientCerts)),
+		Anonymous:   auth.Role(c.AuthAnonymousRole),
+	}
+	for token, role := range c.AuthTokens {
+		cfg.Tokens[token] = auth.Role(role)
+	}
+	if c.AdminToken != "" {
+		cfg.Tokens[c.AdminToken] = auth.RoleAdmin
+	}
+	for name, role := range c.AuthClientCerts {
+		cfg.ClientCerts[name] = auth.Role(role)
+	}
+	if c.AuthOIDCJWKSFile != "" {
+		cfg.JWT = &auth.JWTConfig{
+			JWKSFile:   c.AuthOIDCJWKSFile,
+			Issuer:     c.AuthOIDCIssuer,
+			Audience:   c.AuthOIDCAudience,
+			RolesClaim: c.AuthOIDCRolesClaim,
+		}
+	}
+	return cfg
+}
+
+// validateURLs checks that every URL is absolute and uses one of schemes.
+func validateURLs(name string, urls []string, schemes ...string) error {
+	for _, value := range urls {
+		u, err := url.Parse(value)
+		if err != nil || !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+			return fmt.Errorf("invalid %s %q, must be an absolute %s URL", name, value, strings.Join(schemes, " or "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// fileLayout is the layout of the YAML configuration file: the settings shared by all
+// targets at the top level, and a list of targets with their own settings. Keys are the
+// environment variable names in lower case, e.g. rpc_url or target_prefix.
+type fileLayout struct {
+	Config  `yaml:",inline"`
+	Targets []fileTarget `yaml:"targets"`
+}
+
+// fileTarget is a target in the configuration file.
+type fileTarget struct {
+	Name   string `yaml:"name"`
+	Config `yaml:",inline"`
+}
+
+// file is a parsed configuration file.
+type file struct {
+	data []byte
+	// names lists the targets in the order of the file, nodes holds their settings.
+	names []string
+	nodes map[string]yaml.Node
+}
+
+// readFile reads and strictly validates the configuration file at path. An empty
+// path returns an empty file, which leaves the configuration alone.
+func readFile(path string) (*file, error) {
+	f := &file{nodes: map[string]yaml.Node{}}
+	if path == "" {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	f.data = data
+
+	// Decode strictly first to reject unknown keys and values of the wrong type.
+	var layout fileLayout
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&layout); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	var raw struct {
+		Targets []yaml.Node `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	for i, target := range layout.Targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("invalid config file %s: target %d has no name", path, i+1)
+		}
+		if _, ok := f.nodes[target.Name]; ok {
+			return nil, fmt.Errorf("invalid config file %s: target %s is listed more than once", path, target.Name)
+		}
+		f.names = append(f.names, target.Name)
+		f.nodes[target.Name] = raw.Targets[i]
+	}
+	return f, nil
+}
+
+// apply overrides cfg with the shared settings of the file.
+func (f *file) apply(cfg *Config) error {
+	layout := fileLayout{Config: *cfg}
+	if err := yaml.Unmarshal(f.data, &layout); err != nil {
+		return fmt.Errorf("failed to decode config file: %w", err)
+	}
+	*cfg = layout.Config
+	return nil
+}
+
+// applyTarget overrides cfg with the settings of target in the file, if it has any.
+// It returns the keys the target sets.
+func (f *file) applyTarget(target string, cfg *Config) (map[string]bool, error) {
+	node, ok := f.nodes[target]
+	if !ok {
+		return nil, nil
+	}
+
+	layout := fileTarget{Config: *cfg}
+	if err := node.Decode(&layout); err != nil {
+		return nil, fmt.Errorf("failed to decode target %s in config file: %w", target, err)
+	}
+	*cfg = layout.Config
+
+	var values map[string]any
+	if err := node.Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to decode target %s in config file: %w", target, err)
+	}
+	keys := make(map[string]bool, len(values))
+	for key := range values {
+		keys[key] = true
+	}
+	return keys, nil
+}
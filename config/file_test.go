@@ -0,0 +1,204 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/auth"
+	"github.com/gopher-lab/gopher-updater/config"
+)
+
+var _ = Describe("Configuration file", func() {
+	var path string
+
+	writeFile := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	})
+
+	It("should load the shared settings and keep the defaults of the others", func() {
+		writeFile(`
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+poll_interval: 30s
+rpc_url: [http://node-1:1317, http://node-2:1317]
+`)
+
+		targets, err := config.NewTargets(context.Background(), path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets).To(HaveLen(1))
+		Expect(targets[0].PollInterval).To(Equal(30 * time.Second))
+		Expect(targets[0].RPCURLs).To(Equal([]string{"http://node-1:1317", "http://node-2:1317"}))
+		Expect(targets[0].SourcePrefix).To(Equal("release-"))
+	})
+
+	It("should let non-empty environment variables override the file", func() {
+		writeFile(`
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+poll_interval: 30s
+`)
+		GinkgoT().Setenv("POLL_INTERVAL", "2m")
+		GinkgoT().Setenv("TARGET_PREFIX", "")
+
+		targets, err := config.NewTargets(context.Background(), path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets[0].PollInterval).To(Equal(2 * time.Minute))
+		Expect(targets[0].TargetPrefix).To(Equal("mainnet-"))
+	})
+
+	It("should load targets with their own settings", func() {
+		writeFile(`
+registry_url: https://registry.example.com
+repo_path: my/repo
+state_file: /data/state.json
+targets:
+  - name: testnet
+    rpc_url: [http://testnet:1317]
+    target_prefix: testnet-
+  - name: mainnet
+    rpc_url: [http://mainnet:1317]
+    target_prefix: mainnet-
+    state_file: /data/mainnet.json
+    repositories:
+      - path: my/repo
+      - path: my/price-feeder
+`)
+		GinkgoT().Setenv("MAINNET_TARGET_PREFIX", "prod-")
+
+		targets, err := config.NewTargets(context.Background(), path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets).To(HaveLen(2))
+
+		testnet, mainnet := targets[0], targets[1]
+		Expect(testnet.Target).To(Equal("testnet"))
+		Expect(testnet.RPCURLs).To(Equal([]string{"http://testnet:1317"}))
+		Expect(testnet.TargetPrefix).To(Equal("testnet-"))
+		Expect(testnet.StateFile).To(Equal("/data/state-testnet.json"))
+		Expect(testnet.Repos()).To(HaveLen(1))
+
+		Expect(mainnet.Target).To(Equal("mainnet"))
+		Expect(mainnet.TargetPrefix).To(Equal("prod-"))
+		Expect(mainnet.StateFile).To(Equal("/data/mainnet.json"))
+		Expect(mainnet.Repos()).To(HaveLen(2))
+	})
+
+	It("should load the authentication settings", func() {
+		writeFile(`
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+auth_tokens:
+  dashboard: read
+auth_anonymous_role: none
+`)
+		GinkgoT().Setenv("ADMIN_TOKEN", "s3cret")
+
+		targets, err := config.NewTargets(context.Background(), path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets[0].Auth().Tokens).To(Equal(map[string]auth.Role{"dashboard": auth.RoleRead, "s3cret": auth.RoleAdmin}))
+		Expect(targets[0].Auth().Anonymous).To(Equal(auth.RoleNone))
+		Expect(targets[0].Auth().JWT).To(BeNil())
+	})
+
+	DescribeTable("should reject invalid files",
+		func(content, message string) {
+			writeFile(content)
+			_, err := config.NewTargets(context.Background(), path)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("unknown key", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefx: mainnet-
+`, "line 4: field target_prefx not found"),
+		Entry("unknown key in a target", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+targets:
+  - name: testnet
+    rpc: http://testnet:1317
+`, "field rpc not found"),
+		Entry("bad URL", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+rpc_url: [localhost:1317]
+`, `invalid RPC_URL "localhost:1317", must be an absolute http or https URL`),
+		Entry("zero poll interval", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+poll_interval: 0s
+`, "POLL_INTERVAL must be positive"),
+		Entry("invalid tag prefix", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: main/net-
+`, `invalid target prefix: invalid tag "main/net-"`),
+		Entry("target without a name", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+targets:
+  - target_prefix: testnet-
+`, "target 1 has no name"),
+		Entry("unknown role", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+auth_tokens:
+  s3cret: root
+`, `invalid AUTH_TOKENS: invalid role "root"`),
+		Entry("unknown leader election backend", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+leader_election: etcd
+`, `invalid LEADER_ELECTION "etcd", must be empty, "lease" or "file"`),
+		Entry("short leader election lease", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+leader_election: lease
+leader_election_lease_duration: 500ms
+`, "LEADER_ELECTION_LEASE_DURATION must be at least 1s"),
+		Entry("admin anonymous role", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+auth_anonymous_role: admin
+`, `invalid AUTH_ANONYMOUS_ROLE "admin"`),
+		Entry("client certificates without a CA", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+auth_client_certs:
+  ops.example.com: admin
+`, "AUTH_CLIENT_CERTS requires HTTP_CLIENT_CA_FILE"),
+		Entry("JWKS without an audience", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+auth_oidc_jwks_file: /etc/jwks.json
+auth_oidc_issuer: https://issuer.example.com
+`, "AUTH_OIDC_ISSUER and AUTH_OIDC_AUDIENCE are required"),
+		Entry("public pprof address", `
+registry_url: https://registry.example.com
+repo_path: my/repo
+target_prefix: mainnet-
+pprof_addr: 0.0.0.0:6060
+`, `invalid PPROF_ADDR "0.0.0.0:6060", must be a loopback address`),
+	)
+})
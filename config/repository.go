@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gopher-lab/gopher-updater/pkg/tagtemplate"
+)
+
+// Repository is an image repository promoted on every upgrade, with its own tag rules.
+// Empty source or target rules inherit the global ones, see Config.Repos.
+type Repository struct {
+	Path                string `json:"path" yaml:"path"`
+	SourcePrefix        string `json:"source_prefix,omitempty" yaml:"source_prefix"`
+	TargetPrefix        string `json:"target_prefix,omitempty" yaml:"target_prefix"`
+	SourceTagTemplate   string `json:"source_tag_template,omitempty" yaml:"source_tag_template"`
+	TargetTagTemplate   string `json:"target_tag_template,omitempty" yaml:"target_tag_template"`
+	SourceImageInfoPath string `json:"source_image_info_path,omitempty" yaml:"source_image_info_path"`
+}
+
+// Repositories is a list of repositories, decoded from a JSON array in the environment.
+type Repositories []Repository
+
+// EnvDecode implements envconfig.Decoder. It is also called when REPOSITORIES is unset.
+func (r *Repositories) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	var repos []Repository
+	if err := json.Unmarshal([]byte(val), &repos); err != nil {
+		return fmt.Errorf("failed to decode repositories: %w", err)
+	}
+	*r = repos
+	return nil
+}
+
+// validateRepos checks that every repository has a path and a target tag rule, that
+// its prefixes are valid tags and its templates parse, and that no repository is listed twice.
+func validateRepos(repos []Repository) error {
+	seen := map[string]bool{}
+	for _, repo := range repos {
+		if repo.Path == "" {
+			return fmt.Errorf("every repository needs a path")
+		}
+		if seen[repo.Path] {
+			return fmt.Errorf("repository %s is listed more than once", repo.Path)
+		}
+		seen[repo.Path] = true
+
+		if repo.TargetPrefix == "" && repo.TargetTagTemplate == "" {
+			return fmt.Errorf("repository %s: TARGET_PREFIX or TARGET_TAG_TEMPLATE is required", repo.Path)
+		}
+		for name, prefix := range map[string]string{"source prefix": repo.SourcePrefix, "target prefix": repo.TargetPrefix} {
+			if prefix == "" {
+				continue
+			}
+			if err := tagtemplate.ValidateTag(prefix); err != nil {
+				return fmt.Errorf("repository %s: invalid %s: %w", repo.Path, name, err)
+			}
+		}
+		for name, text := range map[string]string{"source tag template": repo.SourceTagTemplate, "target tag template": repo.TargetTagTemplate} {
+			if text == "" {
+				continue
+			}
+			if _, err := tagtemplate.Parse(text); err != nil {
+				return fmt.Errorf("repository %s: invalid %s: %w", repo.Path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Repos returns the repositories to promote. Without REPOSITORIES, it is the single
+// REPO_PATH repository with the global tag rules. A repository that sets neither a
+// prefix nor a template for its source (or target) tag inherits the global rule.
+func (c *Config) Repos() []Repository {
+	if len(c.Repositories) == 0 {
+		return []Repository{{
+			Path:                c.RepoPath,
+			SourcePrefix:        c.SourcePrefix,
+			TargetPrefix:        c.TargetPrefix,
+			SourceTagTemplate:   c.SourceTagTemplate,
+			TargetTagTemplate:   c.TargetTagTemplate,
+			SourceImageInfoPath: c.SourceImageInfoPath,
+		}}
+	}
+
+	repos := make([]Repository, len(c.Repositories))
+	for i, repo := range c.Repositories {
+		if repo.SourcePrefix == "" && repo.SourceTagTemplate == "" {
+			repo.SourcePrefix, repo.SourceTagTemplate = c.SourcePrefix, c.SourceTagTemplate
+		}
+		if repo.TargetPrefix == "" && repo.TargetTagTemplate == "" {
+			repo.TargetPrefix, repo.TargetTagTemplate = c.TargetPrefix, c.TargetTagTemplate
+		}
+		if repo.SourceImageInfoPath == "" {
+			repo.SourceImageInfoPath = c.SourceImageInfoPath
+		}
+		repos[i] = repo
+	}
+	return repos
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// targetNameRegexp matches valid target names. They end up in variable prefixes and
+// ConfigMap names, so they are limited to lower-case letters, digits and dashes.
+var targetNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// NewTargets loads one configuration per target.
+//
+// Settings are taken from their default, then the YAML configuration file at path, if
+// any, then the environment, each overriding the previous one. The targets are those
+// listed in TARGETS, e.g. TARGETS=testnet,mainnet, or else those of the file.
+//
+// A target starts from the shared settings and overrides them with its own: those in its
+// entry of the file, then the variables prefixed with its upper-cased name, e.g.
+// MAINNET_RPC_URL. Targets keep their state apart: unless the target sets its own
+// STATE_FILE or STATE_CONFIGMAP, the shared one is suffixed with the target name, and
+// unless it sets its own APPROVAL_DIR, it uses a subdirectory of the shared one named
+// after the target.
+//
+// Without targets, a single unnamed target is loaded from the shared settings.
+func NewTargets(ctx context.Context, path string) ([]*Config, error) {
+	return loadTargets(ctx, path, envconfig.OsLookuper())
+}
+
+// loadTargets loads the targets from the file at path and the variables found by lookuper.
+func loadTargets(ctx context.Context, path string, lookuper envconfig.Lookuper) ([]*Config, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := defaults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.apply(base); err != nil {
+		return nil, err
+	}
+	if err := base.applyEnv(ctx, lookuper); err != nil {
+		return nil, err
+	}
+
+	var targets struct {
+		Names []string `env:"TARGETS"`
+	}
+	if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: &targets, Lookuper: lookuper}); err != nil {
+		return nil, err
+	}
+	names := targets.Names
+	if len(names) == 0 {
+		names = f.names
+	}
+
+	if len(names) == 0 {
+		if err := base.validate(); err != nil {
+			return nil, err
+		}
+		return []*Config{base}, nil
+	}
+
+	configs := make([]*Config, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !targetNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid target name %q, must be lower-case letters, digits and dashes", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("target %s is listed more than once", name)
+		}
+		seen[name] = true
+
+		cfg := *base
+		cfg.Target = name
+		fileKeys, err := f.applyTarget(name, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		targetLookuper := envconfig.PrefixLookuper(envPrefix(name), lookuper)
+		if err := cfg.applyEnv(ctx, targetLookuper); err != nil {
+			return nil, fmt.Errorf("target %s: %w", name, err)
+		}
+
+		if value, _ := targetLookuper.Lookup("STATE_FILE"); value == "" && !fileKeys["state_file"] {
+			ext := filepath.Ext(cfg.StateFile)
+			cfg.StateFile = strings.TrimSuffix(cfg.StateFile, ext) + "-" + name + ext
+		}
+		if value, _ := targetLookuper.Lookup("STATE_CONFIGMAP"); value == "" && !fileKeys["state_configmap"] {
+			cfg.StateConfigMap += "-" + name
+		}
+		if value, _ := targetLookuper.Lookup("APPROVAL_DIR"); value == "" && !fileKeys["approval_dir"] && cfg.ApprovalDir != "" {
+			cfg.ApprovalDir = filepath.Join(cfg.ApprovalDir, name)
+		}
+
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("target %s: %w", name, err)
+		}
+		configs = append(configs, &cfg)
+	}
+	return configs, nil
+}
+
+// envPrefix returns the prefix of the variables that only apply to target, e.g. "MAINNET_".
+func envPrefix(target string) string {
+	return strings.ToUpper(strings.ReplaceAll(target, "-", "_")) + "_"
+}
@@ -0,0 +1,87 @@
+package config_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/config"
+)
+
+var _ = Describe("NewTargets", func() {
+	BeforeEach(func() {
+		GinkgoT().Setenv("REGISTRY_URL", "https://registry.example.com")
+		GinkgoT().Setenv("REPO_PATH", "my/repo")
+		GinkgoT().Setenv("TARGET_PREFIX", "mainnet-")
+		GinkgoT().Setenv("STATE_FILE", "/var/lib/gopher-updater/state.json")
+		GinkgoT().Setenv("STATE_CONFIGMAP", "gopher-updater-state")
+	})
+
+	It("should load a single unnamed target without TARGETS", func() {
+		GinkgoT().Setenv("TARGETS", "")
+
+		targets, err := config.NewTargets(context.Background(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets).To(HaveLen(1))
+		Expect(targets[0].Target).To(BeEmpty())
+		Expect(targets[0].StateFile).To(Equal("/var/lib/gopher-updater/state.json"))
+	})
+
+	It("should prefer prefixed variables and fall back to shared ones", func() {
+		GinkgoT().Setenv("TARGETS", "testnet,main-net")
+		GinkgoT().Setenv("TESTNET_RPC_URL", "http://testnet:1317")
+		GinkgoT().Setenv("TESTNET_TARGET_PREFIX", "testnet-")
+		GinkgoT().Setenv("MAIN_NET_RPC_URL", "http://mainnet:1317")
+		GinkgoT().Setenv("MAIN_NET_STATE_FILE", "/data/mainnet.json")
+
+		targets, err := config.NewTargets(context.Background(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets).To(HaveLen(2))
+
+		testnet, mainnet := targets[0], targets[1]
+		Expect(testnet.Target).To(Equal("testnet"))
+		Expect(testnet.RPCURLs).To(Equal([]string{"http://testnet:1317"}))
+		Expect(testnet.TargetPrefix).To(Equal("testnet-"))
+		Expect(testnet.RepoPath).To(Equal("my/repo"))
+		Expect(testnet.StateFile).To(Equal("/var/lib/gopher-updater/state-testnet.json"))
+		Expect(testnet.StateConfigMap).To(Equal("gopher-updater-state-testnet"))
+
+		Expect(mainnet.Target).To(Equal("main-net"))
+		Expect(mainnet.RPCURLs).To(Equal([]string{"http://mainnet:1317"}))
+		Expect(mainnet.TargetPrefix).To(Equal("mainnet-"))
+		Expect(mainnet.StateFile).To(Equal("/data/mainnet.json"))
+	})
+
+	It("should give each target its own approval directory", func() {
+		GinkgoT().Setenv("TARGETS", "testnet,mainnet")
+		GinkgoT().Setenv("APPROVAL_DIR", "/var/lib/gopher-updater/approvals")
+		GinkgoT().Setenv("MAINNET_APPROVAL_DIR", "/approvals")
+		GinkgoT().Setenv("MAINNET_REQUIRE_APPROVAL", "true")
+
+		targets, err := config.NewTargets(context.Background(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targets[0].ApprovalDir).To(Equal("/var/lib/gopher-updater/approvals/testnet"))
+		Expect(targets[0].RequireApproval).To(BeFalse())
+		Expect(targets[1].ApprovalDir).To(Equal("/approvals"))
+		Expect(targets[1].RequireApproval).To(BeTrue())
+	})
+
+	It("should name the target whose configuration is invalid", func() {
+		GinkgoT().Setenv("TARGETS", "testnet,mainnet")
+		GinkgoT().Setenv("MAINNET_CATCH_UP_POLICY", "newest")
+
+		_, err := config.NewTargets(context.Background(), "")
+		Expect(err).To(MatchError(ContainSubstring("target mainnet: invalid CATCH_UP_POLICY")))
+	})
+
+	It("should reject invalid and duplicate target names", func() {
+		GinkgoT().Setenv("TARGETS", "Mainnet")
+		_, err := config.NewTargets(context.Background(), "")
+		Expect(err).To(MatchError(ContainSubstring("invalid target name")))
+
+		GinkgoT().Setenv("TARGETS", "mainnet,mainnet")
+		_, err = config.NewTargets(context.Background(), "")
+		Expect(err).To(MatchError(ContainSubstring("listed more than once")))
+	})
+})
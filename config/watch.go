@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// WatchFile calls onChange whenever the content of the file at path changes, checking
+// every interval until ctx is done. The content is compared rather than the modification
+// time, so updates of a mounted Kubernetes ConfigMap, which swap a symlink, are noticed too.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, err := fileHash(path)
+	if err != nil {
+		xlog.Warn("failed to read config file", "path", path, "err", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hash, err := fileHash(path)
+			if err != nil {
+				xlog.Warn("failed to read config file", "path", path, "err", err)
+				continue
+			}
+			if bytes.Equal(hash, last) {
+				continue
+			}
+			last = hash
+			xlog.Info("config file changed", "path", path)
+			onChange()
+		}
+	}
+}
+
+// fileHash returns the SHA-256 hash of the content of the file at path.
+func fileHash(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
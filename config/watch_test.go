@@ -0,0 +1,34 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/config"
+)
+
+var _ = Describe("WatchFile", func() {
+	It("should report changes of the file content only", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte("poll_interval: 1m\n"), 0o600)).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var changes atomic.Int32
+		go config.WatchFile(ctx, path, 10*time.Millisecond, func() { changes.Add(1) })
+
+		Consistently(changes.Load, 100*time.Millisecond).Should(BeZero())
+		now := time.Now()
+		Expect(os.Chtimes(path, now, now)).To(Succeed())
+		Consistently(changes.Load, 100*time.Millisecond).Should(BeZero())
+
+		Expect(os.WriteFile(path, []byte("poll_interval: 2m\n"), 0o600)).To(Succeed())
+		Eventually(changes.Load).Should(BeEquivalentTo(1))
+	})
+})
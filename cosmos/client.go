@@ -3,38 +3,65 @@ package cosmos
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
 )
 
+// BlockClient defines the methods to query blocks. It is implemented by both the
+// REST Client and the CometBFT RPC CometClient.
+type BlockClient interface {
+	GetLatestBlockHeight(ctx context.Context) (int64, error)
+	GetBlockHeader(ctx context.Context, height int64) (*BlockHeader, error)
+}
+
 // ClientInterface defines the methods to interact with a Cosmos chain.
 type ClientInterface interface {
-	GetLatestBlockHeight(ctx context.Context) (int64, error)
+	BlockClient
 	GetUpgradePlans(ctx context.Context) ([]Plan, error)
+	GetCurrentPlan(ctx context.Context) (*Plan, error)
+	GetAppliedPlanHeight(ctx context.Context, name string) (int64, error)
+}
+
+// ChainIDClient is implemented by clients that can report the chain ID of the node.
+type ChainIDClient interface {
+	GetChainID(ctx context.Context) (string, error)
 }
 
 // Client for interacting with the Cosmos REST API.
 type Client struct {
 	rpcURL     string
 	httpClient *http.Client
+
+	// BlockClient, if set, answers block queries instead of the REST API,
+	// e.g. a CometClient talking to the node's CometBFT RPC endpoint.
+	BlockClient BlockClient
 }
 
 // NewClient creates a new Cosmos client.
 func NewClient(rpcURL string, httpClient *http.Client) *Client {
 	return &Client{
 		rpcURL:     rpcURL,
-		httpClient: httpClient,
+		httpClient: metrics.InstrumentClient("cosmos", httpClient),
 	}
 }
 
-var _ ClientInterface = (*Client)(nil)
+var (
+	_ ClientInterface = (*Client)(nil)
+	_ ChainIDClient   = (*Client)(nil)
+)
 
 // Structs for parsing Cosmos API responses.
 // Simplified for what we need.
 
 type BlockHeader struct {
-	Height string `json:"height"`
+	Height string    `json:"height"`
+	Time   time.Time `json:"time"`
 }
 
 type Block struct {
@@ -47,36 +74,54 @@ type LatestBlockResponse struct {
 
 // GetLatestBlockHeight returns the latest block height of the chain.
 func (c *Client) GetLatestBlockHeight(ctx context.Context) (int64, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rpcURL+"/blocks/latest", nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+	if c.BlockClient != nil {
+		return c.BlockClient.GetLatestBlockHeight(ctx)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	header, err := c.getBlockHeader(ctx, "latest")
+	if errors.Is(err, errRouteNotFound) {
+		// Nodes running an old SDK version only serve the legacy route.
+		header, err = c.getBlockHeaderAt(ctx, "/blocks/latest")
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get latest block: %w", err)
+		return 0, err
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	return parseHeight(header.Height)
+}
+
+// GetBlockHeader returns the header of the block at the given height.
+func (c *Client) GetBlockHeader(ctx context.Context, height int64) (*BlockHeader, error) {
+	if c.BlockClient != nil {
+		return c.BlockClient.GetBlockHeader(ctx, height)
 	}
+	return c.getBlockHeader(ctx, strconv.FormatInt(height, 10))
+}
+
+func (c *Client) getBlockHeader(ctx context.Context, height string) (*BlockHeader, error) {
+	return c.getBlockHeaderAt(ctx, "/cosmos/base/tendermint/v1beta1/blocks/"+height)
+}
 
-	var latestBlockResp LatestBlockResponse
-	if err := json.NewDecoder(resp.Body).Decode(&latestBlockResp); err != nil {
-		return 0, fmt.Errorf("failed to decode response: %w", err)
+func (c *Client) getBlockHeaderAt(ctx context.Context, path string) (*BlockHeader, error) {
+	var blockResp LatestBlockResponse
+	if err := c.getJSON(ctx, path, &blockResp); err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
 	}
+	return &blockResp.Block.Header, nil
+}
 
-	height, err := strconv.ParseInt(latestBlockResp.Block.Header.Height, 10, 64)
+func parseHeight(height string) (int64, error) {
+	h, err := strconv.ParseInt(height, 10, 64)
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse block height: %w", err)
 	}
-	return height, nil
+	return h, nil
 }
 
 type Plan struct {
 	Name   string `json:"name"`
 	Height string `json:"height"`
+	Info   string `json:"info,omitempty"`
 }
 
 type ProposalContent struct {
@@ -90,37 +135,252 @@ type Proposal struct {
 }
 
 type ProposalsResponse struct {
-	Proposals []Proposal `json:"proposals"`
+	Proposals  []Proposal   `json:"proposals"`
+	Pagination PageResponse `json:"pagination"`
+}
+
+// ProposalMessage is a single message of a gov v1 proposal. Upgrades are either
+// submitted directly as MsgSoftwareUpgrade or wrapped as legacy content in a
+// MsgExecLegacyContent.
+type ProposalMessage struct {
+	Type    string           `json:"@type"`
+	Plan    Plan             `json:"plan"`
+	Content *ProposalContent `json:"content,omitempty"`
+}
+
+type ProposalV1 struct {
+	ID       string            `json:"id"`
+	Status   string            `json:"status"`
+	Messages []ProposalMessage `json:"messages"`
+}
+
+type ProposalsV1Response struct {
+	Proposals  []ProposalV1 `json:"proposals"`
+	Pagination PageResponse `json:"pagination"`
 }
 
+// PageResponse is the pagination section of a Cosmos SDK list response.
+type PageResponse struct {
+	NextKey string `json:"next_key"`
+	Total   string `json:"total"`
+}
+
+func (r *ProposalsResponse) page() (PageResponse, int)   { return r.Pagination, len(r.Proposals) }
+func (r *ProposalsV1Response) page() (PageResponse, int) { return r.Pagination, len(r.Proposals) }
+
+const (
+	proposalStatusPassed = "PROPOSAL_STATUS_PASSED"
+
+	// proposalsPageLimit is the number of proposals requested per page.
+	proposalsPageLimit = 100
+	// maxProposalPages caps how many pages we follow, guarding against a node
+	// that keeps returning a next_key forever.
+	maxProposalPages = 100
+
+	typeSoftwareUpgradeProposal = "/cosmos.upgrade.v1beta1.SoftwareUpgradeProposal"
+	typeMsgSoftwareUpgrade      = "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade"
+	typeMsgExecLegacyContent    = "/cosmos.gov.v1.MsgExecLegacyContent"
+)
+
+// errRouteNotFound is returned by getJSON when the node does not serve the route,
+// which is how we detect chains that predate gov v1.
+var errRouteNotFound = errors.New("route not found")
+
 // GetUpgradePlans finds all passed software upgrade proposals and returns their plans.
+// It queries gov v1 and falls back to gov v1beta1 on chains that do not serve it.
 func (c *Client) GetUpgradePlans(ctx context.Context) ([]Plan, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rpcURL+"/cosmos/gov/v1beta1/proposals", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	plans, err := c.getUpgradePlansV1(ctx)
+	if errors.Is(err, errRouteNotFound) {
+		return c.getUpgradePlansV1Beta1(ctx)
 	}
+	return plans, err
+}
 
-	resp, err := c.httpClient.Do(req)
+func (c *Client) getUpgradePlansV1(ctx context.Context) ([]Plan, error) {
+	var pages []*ProposalsV1Response
+	err := c.getAllPages(ctx, "/cosmos/gov/v1/proposals", passedProposalsQuery(), func() pagedResponse {
+		page := &ProposalsV1Response{}
+		pages = append(pages, page)
+		return page
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get proposals: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	var plans []Plan
+	for _, page := range pages {
+		for _, p := range page.Proposals {
+			if p.Status != proposalStatusPassed {
+				continue
+			}
+			for _, msg := range p.Messages {
+				switch {
+				case msg.Type == typeMsgSoftwareUpgrade:
+					plans = append(plans, msg.Plan)
+				case msg.Type == typeMsgExecLegacyContent && msg.Content != nil && msg.Content.Type == typeSoftwareUpgradeProposal:
+					plans = append(plans, msg.Content.Plan)
+				}
+			}
+		}
 	}
 
-	var proposalsResp ProposalsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&proposalsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode proposals response: %w", err)
+	return plans, nil
+}
+
+func (c *Client) getUpgradePlansV1Beta1(ctx context.Context) ([]Plan, error) {
+	var pages []*ProposalsResponse
+	err := c.getAllPages(ctx, "/cosmos/gov/v1beta1/proposals", passedProposalsQuery(), func() pagedResponse {
+		page := &ProposalsResponse{}
+		pages = append(pages, page)
+		return page
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposals: %w", err)
 	}
 
 	var plans []Plan
-	for _, p := range proposalsResp.Proposals {
-		if p.Status == "PROPOSAL_STATUS_PASSED" && p.Content.Type == "/cosmos.upgrade.v1beta1.SoftwareUpgradeProposal" {
-			plans = append(plans, p.Content.Plan)
+	for _, page := range pages {
+		for _, p := range page.Proposals {
+			if p.Status == proposalStatusPassed && p.Content.Type == typeSoftwareUpgradeProposal {
+				plans = append(plans, p.Content.Plan)
+			}
 		}
 	}
 
 	return plans, nil
 }
+
+type CurrentPlanResponse struct {
+	Plan *Plan `json:"plan"`
+}
+
+type AppliedPlanResponse struct {
+	Height string `json:"height"`
+}
+
+// GetCurrentPlan returns the plan currently scheduled in the x/upgrade module,
+// or nil if there is none. Unlike passed proposals, this reflects cancellations
+// and plans that were replaced by a newer one.
+func (c *Client) GetCurrentPlan(ctx context.Context) (*Plan, error) {
+	var currentPlanResp CurrentPlanResponse
+	if err := c.getJSON(ctx, "/cosmos/upgrade/v1beta1/current_plan", &currentPlanResp); err != nil {
+		return nil, fmt.Errorf("failed to get current plan: %w", err)
+	}
+	return currentPlanResp.Plan, nil
+}
+
+// GetAppliedPlanHeight returns the height at which the named plan was applied,
+// or 0 if it has not been applied.
+func (c *Client) GetAppliedPlanHeight(ctx context.Context, name string) (int64, error) {
+	var appliedPlanResp AppliedPlanResponse
+	if err := c.getJSON(ctx, "/cosmos/upgrade/v1beta1/applied_plan/"+url.PathEscape(name), &appliedPlanResp); err != nil {
+		return 0, fmt.Errorf("failed to get applied plan: %w", err)
+	}
+
+	height, err := strconv.ParseInt(appliedPlanResp.Height, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse applied plan height: %w", err)
+	}
+	return height, nil
+}
+
+type nodeInfoResponse struct {
+	DefaultNodeInfo NodeInfo `json:"default_node_info"`
+}
+
+// GetChainID returns the chain ID the node reports in its node info.
+func (c *Client) GetChainID(ctx context.Context) (string, error) {
+	var nodeInfoResp nodeInfoResponse
+	if err := c.getJSON(ctx, "/cosmos/base/tendermint/v1beta1/node_info", &nodeInfoResp); err != nil {
+		return "", fmt.Errorf("failed to get node info: %w", err)
+	}
+	if nodeInfoResp.DefaultNodeInfo.Network == "" {
+		return "", errors.New("node info does not contain a chain id")
+	}
+	return nodeInfoResp.DefaultNodeInfo.Network, nil
+}
+
+// passedProposalsQuery asks the node to filter proposals server-side. We still
+// check the status client-side in case a node ignores the filter.
+func passedProposalsQuery() url.Values {
+	q := url.Values{}
+	q.Set("proposal_status", proposalStatusPassed)
+	return q
+}
+
+type pagedResponse interface {
+	page() (PageResponse, int)
+}
+
+// getAllPages follows the pagination of a list endpoint until every page has been
+// fetched. newPage is called once per page and must return a fresh value to decode into.
+// It follows next_key when the node returns one, and falls back to offset pagination
+// when the node reports a total larger than what has been fetched so far.
+func (c *Client) getAllPages(ctx context.Context, path string, query url.Values, newPage func() pagedResponse) error {
+	var (
+		nextKey string
+		fetched int
+	)
+	for pageNum := 0; pageNum < maxProposalPages; pageNum++ {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("pagination.limit", strconv.Itoa(proposalsPageLimit))
+		q.Set("pagination.count_total", "true")
+		switch {
+		case nextKey != "":
+			q.Set("pagination.key", nextKey)
+		case fetched > 0:
+			q.Set("pagination.offset", strconv.Itoa(fetched))
+		}
+
+		page := newPage()
+		if err := c.getJSON(ctx, path+"?"+q.Encode(), page); err != nil {
+			return err
+		}
+
+		pagination, n := page.page()
+		fetched += n
+		if n == 0 {
+			return nil
+		}
+		if pagination.NextKey != "" {
+			nextKey = pagination.NextKey
+			continue
+		}
+		total, err := strconv.Atoi(pagination.Total)
+		if err != nil || total <= fetched {
+			return nil
+		}
+		nextKey = ""
+	}
+	return fmt.Errorf("%s: exceeded the maximum of %d pages", path, maxProposalPages)
+}
+
+// getJSON performs a GET request against the REST API and decodes the JSON response into out.
+func (c *Client) getJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rpcURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return fmt.Errorf("%w: %s returned %d", errRouteNotFound, path, resp.StatusCode)
+	default:
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
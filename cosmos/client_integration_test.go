@@ -43,6 +43,32 @@ var _ = Describe("Client Integration", func() {
 			Expect(height).To(BeEquivalentTo(12345))
 		})
 
+		It("should prefer the tendermint service route", func() {
+			mux.HandleFunc("/cosmos/base/tendermint/v1beta1/blocks/latest", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"block":{"header":{"height":"23456"}}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+			mux.HandleFunc("/blocks/latest", func(w http.ResponseWriter, r *http.Request) {
+				Fail("legacy route should not be queried")
+			})
+
+			height, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(23456))
+		})
+
+		It("should delegate to the configured block client", func() {
+			client.BlockClient = cosmos.NewClient(server.URL+"/other", server.Client())
+			mux.HandleFunc("/other/cosmos/base/tendermint/v1beta1/blocks/latest", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"block":{"header":{"height":"42"}}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			height, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(42))
+		})
+
 		It("should return an error on a non-200 status code", func() {
 			mux.HandleFunc("/blocks/latest", func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusInternalServerError)
@@ -63,8 +89,172 @@ var _ = Describe("Client Integration", func() {
 		})
 	})
 
+	Describe("GetBlockHeader", func() {
+		It("should return the header of the requested block", func() {
+			mux.HandleFunc("/cosmos/base/tendermint/v1beta1/blocks/100", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"block":{"header":{"height":"100","time":"2024-01-02T03:04:05Z"}}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			header, err := client.GetBlockHeader(ctx, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header.Height).To(Equal("100"))
+			Expect(header.Time.Unix()).To(BeEquivalentTo(1704164645))
+		})
+	})
+
 	Describe("GetUpgradePlans", func() {
-		It("should correctly parse and filter for passed software upgrade proposals", func() {
+		It("should parse MsgSoftwareUpgrade messages from gov v1 proposals", func() {
+			mux.HandleFunc("/cosmos/gov/v1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{
+					"proposals": [
+						{
+							"id": "1",
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [
+								{
+									"@type": "/cosmos.bank.v1beta1.MsgSend"
+								},
+								{
+									"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade",
+									"authority": "cosmos10d07y265gmmuvt4z0w9aw880jnsr700j6zn9kn",
+									"plan": { "name": "v2.0.0", "height": "300", "info": "{\"image\": \"release-v2.0.0\"}" }
+								}
+							]
+						},
+						{
+							"id": "2",
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [
+								{
+									"@type": "/cosmos.gov.v1.MsgExecLegacyContent",
+									"content": {
+										"@type": "/cosmos.upgrade.v1beta1.SoftwareUpgradeProposal",
+										"plan": { "name": "v2.1.0", "height": "400" }
+									}
+								}
+							]
+						},
+						{
+							"id": "3",
+							"status": "PROPOSAL_STATUS_REJECTED",
+							"messages": [
+								{
+									"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade",
+									"plan": { "name": "v2.2.0", "height": "500" }
+								}
+							]
+						}
+					]
+				}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+			mux.HandleFunc("/cosmos/gov/v1beta1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				Fail("v1beta1 should not be queried when v1 is available")
+			})
+
+			plans, err := client.GetUpgradePlans(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(Equal([]cosmos.Plan{
+				{Name: "v2.0.0", Height: "300", Info: `{"image": "release-v2.0.0"}`},
+				{Name: "v2.1.0", Height: "400"},
+			}))
+		})
+
+		It("should not fall back to v1beta1 when gov v1 returns a server error", func() {
+			mux.HandleFunc("/cosmos/gov/v1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+			mux.HandleFunc("/cosmos/gov/v1beta1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				Fail("v1beta1 should not be queried on a v1 server error")
+			})
+
+			_, err := client.GetUpgradePlans(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should follow next_key pagination and filter by passed status", func() {
+			mux.HandleFunc("/cosmos/gov/v1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("proposal_status")).To(Equal("PROPOSAL_STATUS_PASSED"))
+				switch r.URL.Query().Get("pagination.key") {
+				case "":
+					_, err := fmt.Fprint(w, `{
+						"proposals": [{
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [{"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade", "plan": {"name": "v1.0.0", "height": "100"}}]
+						}],
+						"pagination": {"next_key": "cGFnZTI=", "total": "2"}
+					}`)
+					Expect(err).NotTo(HaveOccurred())
+				case "cGFnZTI=":
+					_, err := fmt.Fprint(w, `{
+						"proposals": [{
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [{"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade", "plan": {"name": "v2.0.0", "height": "200"}}]
+						}],
+						"pagination": {"next_key": null, "total": "0"}
+					}`)
+					Expect(err).NotTo(HaveOccurred())
+				default:
+					Fail("unexpected pagination key " + r.URL.Query().Get("pagination.key"))
+				}
+			})
+
+			plans, err := client.GetUpgradePlans(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(Equal([]cosmos.Plan{
+				{Name: "v1.0.0", Height: "100"},
+				{Name: "v2.0.0", Height: "200"},
+			}))
+		})
+
+		It("should fall back to offset pagination when no next_key is returned", func() {
+			mux.HandleFunc("/cosmos/gov/v1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Query().Get("pagination.offset") {
+				case "":
+					_, err := fmt.Fprint(w, `{
+						"proposals": [{
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [{"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade", "plan": {"name": "v1.0.0", "height": "100"}}]
+						}],
+						"pagination": {"total": "2"}
+					}`)
+					Expect(err).NotTo(HaveOccurred())
+				case "1":
+					_, err := fmt.Fprint(w, `{
+						"proposals": [{
+							"status": "PROPOSAL_STATUS_PASSED",
+							"messages": [{"@type": "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade", "plan": {"name": "v2.0.0", "height": "200"}}]
+						}],
+						"pagination": {"total": "2"}
+					}`)
+					Expect(err).NotTo(HaveOccurred())
+				default:
+					Fail("unexpected pagination offset " + r.URL.Query().Get("pagination.offset"))
+				}
+			})
+
+			plans, err := client.GetUpgradePlans(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(HaveLen(2))
+			Expect(plans[1].Name).To(Equal("v2.0.0"))
+		})
+
+		It("should return an error when the node never stops paginating", func() {
+			mux.HandleFunc("/cosmos/gov/v1/proposals", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{
+					"proposals": [{"status": "PROPOSAL_STATUS_PASSED", "messages": []}],
+					"pagination": {"next_key": "bW9yZQ=="}
+				}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			_, err := client.GetUpgradePlans(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("maximum"))
+		})
+
+		It("should fall back to v1beta1 and filter for passed software upgrade proposals", func() {
 			mux.HandleFunc("/cosmos/gov/v1beta1/proposals", func(w http.ResponseWriter, r *http.Request) {
 				_, err := fmt.Fprint(w, `{
 					"proposals": [
@@ -120,4 +310,83 @@ var _ = Describe("Client Integration", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("GetCurrentPlan", func() {
+		It("should return the scheduled plan", func() {
+			mux.HandleFunc("/cosmos/upgrade/v1beta1/current_plan", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"plan": {"name": "v1.2.3", "height": "100", "info": ""}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			plan, err := client.GetCurrentPlan(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan).To(Equal(&cosmos.Plan{Name: "v1.2.3", Height: "100"}))
+		})
+
+		It("should return nil when no plan is scheduled", func() {
+			mux.HandleFunc("/cosmos/upgrade/v1beta1/current_plan", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"plan": null}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			plan, err := client.GetCurrentPlan(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan).To(BeNil())
+		})
+
+		It("should return an error on a non-200 status code", func() {
+			mux.HandleFunc("/cosmos/upgrade/v1beta1/current_plan", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+
+			_, err := client.GetCurrentPlan(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetAppliedPlanHeight", func() {
+		It("should return the height at which the plan was applied", func() {
+			mux.HandleFunc("/cosmos/upgrade/v1beta1/applied_plan/v1.2.3", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"height": "100"}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			height, err := client.GetAppliedPlanHeight(ctx, "v1.2.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(100))
+		})
+
+		It("should return zero for a plan that was never applied", func() {
+			mux.HandleFunc("/cosmos/upgrade/v1beta1/applied_plan/v9.9.9", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"height": "0"}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			height, err := client.GetAppliedPlanHeight(ctx, "v9.9.9")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeZero())
+		})
+	})
+	Describe("GetChainID", func() {
+		It("should return the network from the node info", func() {
+			mux.HandleFunc("/cosmos/base/tendermint/v1beta1/node_info", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"default_node_info": {"network": "gopher-1", "version": "0.38.17"}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			chainID, err := client.GetChainID(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chainID).To(Equal("gopher-1"))
+		})
+
+		It("should return an error when the node info has no network", func() {
+			mux.HandleFunc("/cosmos/base/tendermint/v1beta1/node_info", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{"default_node_info": {}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			_, err := client.GetChainID(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,137 @@
+package cosmos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+)
+
+// StatusClient is implemented by block clients that can report the sync status of the node.
+type StatusClient interface {
+	GetStatus(ctx context.Context) (*NodeStatus, error)
+}
+
+// CometClient for interacting with the CometBFT RPC endpoint of a node (usually on port 26657).
+type CometClient struct {
+	rpcURL     string
+	httpClient *http.Client
+}
+
+// NewCometClient creates a new CometBFT RPC client.
+func NewCometClient(rpcURL string, httpClient *http.Client) *CometClient {
+	return &CometClient{
+		rpcURL:     rpcURL,
+		httpClient: metrics.InstrumentClient("cometbft", httpClient),
+	}
+}
+
+var (
+	_ BlockClient  = (*CometClient)(nil)
+	_ StatusClient = (*CometClient)(nil)
+)
+
+// Structs for parsing CometBFT RPC responses.
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type SyncInfo struct {
+	LatestBlockHeight string    `json:"latest_block_height"`
+	LatestBlockTime   time.Time `json:"latest_block_time"`
+	CatchingUp        bool      `json:"catching_up"`
+}
+
+type NodeInfo struct {
+	Network string `json:"network"`
+	Version string `json:"version"`
+}
+
+type NodeStatus struct {
+	NodeInfo NodeInfo `json:"node_info"`
+	SyncInfo SyncInfo `json:"sync_info"`
+}
+
+type cometBlockResult struct {
+	Block Block `json:"block"`
+}
+
+// GetStatus returns the node's status from the /status route.
+func (c *CometClient) GetStatus(ctx context.Context) (*NodeStatus, error) {
+	var status NodeStatus
+	if err := c.call(ctx, "/status", nil, &status); err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	return &status, nil
+}
+
+// GetLatestBlockHeight returns the latest block height known to the node.
+func (c *CometClient) GetLatestBlockHeight(ctx context.Context) (int64, error) {
+	status, err := c.GetStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return parseHeight(status.SyncInfo.LatestBlockHeight)
+}
+
+// GetBlockHeader returns the header of the block at the given height from the /block route.
+func (c *CometClient) GetBlockHeader(ctx context.Context, height int64) (*BlockHeader, error) {
+	query := url.Values{}
+	query.Set("height", strconv.FormatInt(height, 10))
+
+	var result cometBlockResult
+	if err := c.call(ctx, "/block", query, &result); err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+	return &result.Block.Header, nil
+}
+
+// call performs a JSON-RPC over HTTP GET request and decodes the result into out.
+func (c *CometClient) call(ctx context.Context, path string, query url.Values, out any) error {
+	reqURL := c.rpcURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("rpc error %d: %s: %s", rpcResp.Error.Code, rpcResp.Error.Message, rpcResp.Error.Data)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(rpcResp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode result: %w", err)
+	}
+	return nil
+}
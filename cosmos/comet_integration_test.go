@@ -0,0 +1,94 @@
+package cosmos_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+)
+
+var _ = Describe("CometClient Integration", func() {
+	var (
+		mux    *http.ServeMux
+		server *httptest.Server
+		client *cosmos.CometClient
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mux = http.NewServeMux()
+		server = httptest.NewServer(mux)
+		client = cosmos.NewCometClient(server.URL, server.Client())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("GetStatus", func() {
+		It("should parse the node status", func() {
+			mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+				_, err := fmt.Fprint(w, `{
+					"jsonrpc": "2.0",
+					"id": -1,
+					"result": {
+						"node_info": {"network": "gopher-1", "version": "0.38.12"},
+						"sync_info": {
+							"latest_block_height": "12345",
+							"latest_block_time": "2024-01-02T03:04:05.123456789Z",
+							"catching_up": true
+						}
+					}
+				}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			status, err := client.GetStatus(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.NodeInfo.Network).To(Equal("gopher-1"))
+			Expect(status.SyncInfo.CatchingUp).To(BeTrue())
+
+			height, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(12345))
+		})
+
+		It("should return an error on a JSON-RPC error", func() {
+			mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, err := fmt.Fprint(w, `{"jsonrpc":"2.0","id":-1,"error":{"code":-32603,"message":"Internal error","data":"boom"}}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			_, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("boom"))
+		})
+	})
+
+	Describe("GetBlockHeader", func() {
+		It("should return the header of the requested block", func() {
+			mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("height")).To(Equal("100"))
+				_, err := fmt.Fprint(w, `{
+					"jsonrpc": "2.0",
+					"id": -1,
+					"result": {"block": {"header": {"height": "100", "time": "2024-01-02T03:04:05Z"}}}
+				}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			header, err := client.GetBlockHeader(ctx, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header.Height).To(Equal("100"))
+			Expect(header.Time).To(Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+		})
+	})
+})
@@ -0,0 +1,234 @@
+package cosmos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// QuorumChecker is implemented by clients that can confirm a block height with several nodes.
+type QuorumChecker interface {
+	// HeightReached reports whether enough nodes report a height at or above height.
+	HeightReached(ctx context.Context, height int64) (bool, error)
+}
+
+// Endpoint is a named Cosmos client used by the FailoverClient.
+type Endpoint struct {
+	Name   string
+	Client ClientInterface
+}
+
+type endpointState struct {
+	Endpoint
+	unhealthyUntil time.Time
+}
+
+// FailoverClient spreads requests over several Cosmos nodes. Requests go to the
+// preferred endpoint; when it fails, the endpoint is marked unhealthy for a cooldown
+// and the request is retried on the next one.
+type FailoverClient struct {
+	mu        sync.Mutex
+	endpoints []*endpointState
+	preferred int
+	cooldown  time.Duration
+	quorum    int
+}
+
+// NewFailoverClient creates a new failover client. A quorum greater than one makes
+// HeightReached require that many nodes to agree; otherwise a single node suffices.
+func NewFailoverClient(endpoints []Endpoint, cooldown time.Duration, quorum int) *FailoverClient {
+	states := make([]*endpointState, len(endpoints))
+	for i, e := range endpoints {
+		states[i] = &endpointState{Endpoint: e}
+	}
+	return &FailoverClient{
+		endpoints: states,
+		cooldown:  cooldown,
+		quorum:    quorum,
+	}
+}
+
+var (
+	_ ClientInterface = (*FailoverClient)(nil)
+	_ QuorumChecker   = (*FailoverClient)(nil)
+	_ ChainIDClient   = (*FailoverClient)(nil)
+	_ StatusClient    = (*FailoverClient)(nil)
+)
+
+// GetLatestBlockHeight returns the latest block height from the first healthy endpoint.
+func (f *FailoverClient) GetLatestBlockHeight(ctx context.Context) (int64, error) {
+	return failover(ctx, f, func(c ClientInterface) (int64, error) {
+		return c.GetLatestBlockHeight(ctx)
+	})
+}
+
+// GetBlockHeader returns the block header from the first healthy endpoint.
+func (f *FailoverClient) GetBlockHeader(ctx context.Context, height int64) (*BlockHeader, error) {
+	return failover(ctx, f, func(c ClientInterface) (*BlockHeader, error) {
+		return c.GetBlockHeader(ctx, height)
+	})
+}
+
+// GetUpgradePlans returns the upgrade plans from the first healthy endpoint.
+func (f *FailoverClient) GetUpgradePlans(ctx context.Context) ([]Plan, error) {
+	return failover(ctx, f, func(c ClientInterface) ([]Plan, error) {
+		return c.GetUpgradePlans(ctx)
+	})
+}
+
+// GetCurrentPlan returns the current plan from the first healthy endpoint.
+func (f *FailoverClient) GetCurrentPlan(ctx context.Context) (*Plan, error) {
+	return failover(ctx, f, func(c ClientInterface) (*Plan, error) {
+		return c.GetCurrentPlan(ctx)
+	})
+}
+
+// GetAppliedPlanHeight returns the applied plan height from the first healthy endpoint.
+func (f *FailoverClient) GetAppliedPlanHeight(ctx context.Context, name string) (int64, error) {
+	return failover(ctx, f, func(c ClientInterface) (int64, error) {
+		return c.GetAppliedPlanHeight(ctx, name)
+	})
+}
+
+// GetChainID returns the chain ID from the first healthy endpoint.
+func (f *FailoverClient) GetChainID(ctx context.Context) (string, error) {
+	return failover(ctx, f, func(c ClientInterface) (string, error) {
+		chainIDClient, ok := c.(ChainIDClient)
+		if !ok {
+			return "", errors.New("client cannot report the chain id")
+		}
+		return chainIDClient.GetChainID(ctx)
+	})
+}
+
+// GetStatus returns the sync status from the first healthy endpoint. Only endpoints
+// whose block client reports it, i.e. a CometClient, can; without one, it returns an
+// error wrapping errors.ErrUnsupported.
+func (f *FailoverClient) GetStatus(ctx context.Context) (*NodeStatus, error) {
+	if len(f.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints to report the node status: %w", errors.ErrUnsupported)
+	}
+	if _, ok := statusClient(f.endpoints[0].Client); !ok {
+		return nil, fmt.Errorf("endpoints cannot report the node status: %w", errors.ErrUnsupported)
+	}
+	return failover(ctx, f, func(c ClientInterface) (*NodeStatus, error) {
+		statusClient, ok := statusClient(c)
+		if !ok {
+			return nil, fmt.Errorf("client cannot report the node status: %w", errors.ErrUnsupported)
+		}
+		return statusClient.GetStatus(ctx)
+	})
+}
+
+// statusClient returns the client reporting the sync status of the node behind c,
+// which is c itself or its block client.
+func statusClient(c ClientInterface) (StatusClient, bool) {
+	if statusClient, ok := c.(StatusClient); ok {
+		return statusClient, true
+	}
+	if client, ok := c.(*Client); ok {
+		statusClient, ok := client.BlockClient.(StatusClient)
+		return statusClient, ok
+	}
+	return nil, false
+}
+
+// HeightReached queries every endpoint concurrently and reports whether at least
+// quorum of them are at or above height. This protects against a single lying or
+// forked node triggering a retag. Without a quorum it always returns true.
+func (f *FailoverClient) HeightReached(ctx context.Context, height int64) (bool, error) {
+	if f.quorum <= 1 {
+		return true, nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		reached int
+		errs    []error
+	)
+	for _, e := range f.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := e.Client.GetLatestBlockHeight(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+				return
+			}
+			if h >= height {
+				reached++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reached >= f.quorum {
+		return true, nil
+	}
+	if len(f.endpoints)-len(errs) < f.quorum {
+		return false, fmt.Errorf("not enough endpoints responded to reach a quorum of %d: %w", f.quorum, errors.Join(errs...))
+	}
+	xlog.Warn("block height quorum not reached", "height", height, "reached", reached, "quorum", f.quorum)
+	return false, nil
+}
+
+// failover calls fn on each endpoint in turn, starting with the preferred healthy one,
+// until one succeeds. If every endpoint is in its cooldown, all of them are tried anyway.
+func failover[T any](ctx context.Context, f *FailoverClient, fn func(ClientInterface) (T, error)) (T, error) {
+	var errs []error
+	for _, e := range f.candidates() {
+		result, err := fn(e.Client)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			var zero T
+			return zero, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+		f.markUnhealthy(e)
+	}
+
+	var zero T
+	return zero, fmt.Errorf("all endpoints failed: %w", errors.Join(errs...))
+}
+
+// candidates returns the endpoints to try, healthy ones first, in rotation order.
+func (f *FailoverClient) candidates() []*endpointState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	var healthy, unhealthy []*endpointState
+	for i := range f.endpoints {
+		e := f.endpoints[(f.preferred+i)%len(f.endpoints)]
+		if now.Before(e.unhealthyUntil) {
+			unhealthy = append(unhealthy, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// markUnhealthy puts e in its cooldown and rotates the preferred endpoint past it.
+func (f *FailoverClient) markUnhealthy(e *endpointState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e.unhealthyUntil = time.Now().Add(f.cooldown)
+	for i, candidate := range f.endpoints {
+		if candidate == e && f.preferred == i {
+			f.preferred = (i + 1) % len(f.endpoints)
+		}
+	}
+	xlog.Warn("cosmos endpoint marked unhealthy", "endpoint", e.Name, "cooldown", f.cooldown)
+}
@@ -0,0 +1,117 @@
+package cosmos_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+)
+
+var _ = Describe("FailoverClient Integration", func() {
+	var (
+		servers []*httptest.Server
+		heights []*atomic.Int64
+		hits    []*atomic.Int32
+		ctx     context.Context
+	)
+
+	// newNode starts a fake node serving the given height. A negative height makes it fail.
+	newNode := func(height int64) cosmos.Endpoint {
+		h := &atomic.Int64{}
+		h.Store(height)
+		n := &atomic.Int32{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n.Add(1)
+			if h.Load() < 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, err := fmt.Fprintf(w, `{"block":{"header":{"height":"%d"}}}`, h.Load())
+			Expect(err).NotTo(HaveOccurred())
+		}))
+		servers = append(servers, server)
+		heights = append(heights, h)
+		hits = append(hits, n)
+		return cosmos.Endpoint{Name: server.URL, Client: cosmos.NewClient(server.URL, server.Client())}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		servers, heights, hits = nil, nil, nil
+	})
+
+	AfterEach(func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	})
+
+	Describe("failover", func() {
+		It("should fail over to the next endpoint and keep using it during the cooldown", func() {
+			client := cosmos.NewFailoverClient([]cosmos.Endpoint{newNode(-1), newNode(100)}, time.Hour, 0)
+
+			height, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(100))
+			firstHits := hits[0].Load()
+			Expect(firstHits).To(BeNumerically(">", 0))
+
+			// The first node recovered, but is still in its cooldown.
+			heights[0].Store(50)
+			height, err = client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(100))
+			Expect(hits[0].Load()).To(Equal(firstHits))
+		})
+
+		It("should retry unhealthy endpoints once the cooldown expires", func() {
+			client := cosmos.NewFailoverClient([]cosmos.Endpoint{newNode(-1), newNode(-1)}, time.Millisecond, 0)
+
+			_, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("all endpoints failed"))
+
+			heights[0].Store(100)
+			time.Sleep(5 * time.Millisecond)
+			height, err := client.GetLatestBlockHeight(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).To(BeEquivalentTo(100))
+		})
+	})
+
+	Describe("HeightReached", func() {
+		It("should require a quorum of nodes at or above the height", func() {
+			client := cosmos.NewFailoverClient([]cosmos.Endpoint{newNode(100), newNode(99), newNode(101)}, time.Hour, 2)
+
+			reached, err := client.HeightReached(ctx, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reached).To(BeTrue())
+
+			reached, err = client.HeightReached(ctx, 101)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reached).To(BeFalse())
+		})
+
+		It("should return an error when too few nodes respond to reach a quorum", func() {
+			client := cosmos.NewFailoverClient([]cosmos.Endpoint{newNode(100), newNode(-1), newNode(-1)}, time.Hour, 2)
+
+			_, err := client.HeightReached(ctx, 100)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should always succeed without a quorum", func() {
+			client := cosmos.NewFailoverClient([]cosmos.Endpoint{newNode(-1)}, time.Hour, 0)
+
+			reached, err := client.HeightReached(ctx, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reached).To(BeTrue())
+		})
+	})
+})
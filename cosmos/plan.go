@@ -0,0 +1,58 @@
+package cosmos
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseInfo decodes the plan's info field as JSON. It returns nil if info is empty.
+// Info is free-form, so callers should expect an error for plans that carry plain
+// text or a URL instead.
+func (p Plan) ParseInfo() (any, error) {
+	if strings.TrimSpace(p.Info) == "" {
+		return nil, nil
+	}
+	var info any
+	if err := json.Unmarshal([]byte(p.Info), &info); err != nil {
+		return nil, fmt.Errorf("plan info is not valid JSON: %w", err)
+	}
+	return info, nil
+}
+
+// InfoString returns the string at path in the plan's JSON info. path is a list of
+// object keys and array indexes separated by dots, e.g. images.0.ref. It reports false
+// if info is not JSON or has nothing at path, and fails if the value is not a string.
+func (p Plan) InfoString(path string) (string, bool, error) {
+	info, err := p.ParseInfo()
+	if err != nil || info == nil {
+		return "", false, nil
+	}
+
+	value := info
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return "", false, nil
+			}
+			value = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false, nil
+			}
+			value = v[i]
+		default:
+			return "", false, nil
+		}
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", false, fmt.Errorf("plan info value at %s is not a string", path)
+	}
+	return s, true, nil
+}
@@ -0,0 +1,59 @@
+package cosmos_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+)
+
+var _ = Describe("Plan", func() {
+	Describe("InfoString", func() {
+		plan := cosmos.Plan{Name: "v2", Height: "100", Info: `{
+			"binaries": {"linux/amd64": "https://example.com/gopherd"},
+			"images": [{"ref": "gopher-lab/gopher@sha256:abc"}],
+			"height": 100
+		}`}
+
+		It("should follow object keys and array indexes", func() {
+			value, ok, err := plan.InfoString("images.0.ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("gopher-lab/gopher@sha256:abc"))
+		})
+
+		It("should report missing paths", func() {
+			_, ok, err := plan.InfoString("images.1.ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			_, ok, err = plan.InfoString("docker.image")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should fail when the value is not a string", func() {
+			_, _, err := plan.InfoString("height")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should report nothing for info that is not JSON", func() {
+			_, ok, err := cosmos.Plan{Info: "https://example.com/upgrade.json"}.InfoString("image")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("ParseInfo", func() {
+		It("should return nil for empty info", func() {
+			info, err := cosmos.Plan{}.ParseInfo()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(BeNil())
+		})
+
+		It("should fail for info that is not JSON", func() {
+			_, err := cosmos.Plan{Info: "see forum post"}.ParseInfo()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,192 @@
+package cosmos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// SubscriberInterface delivers the heights of new blocks as they are committed.
+type SubscriberInterface interface {
+	Subscribe(ctx context.Context) <-chan int64
+}
+
+// BlockSubscriber subscribes to NewBlock events over the CometBFT websocket.
+// It reconnects with exponential backoff whenever the connection drops, moving on
+// to the next endpoint if several are configured.
+type BlockSubscriber struct {
+	endpoints []wsEndpoint
+
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	ReadTimeout time.Duration
+}
+
+// wsEndpoint is the websocket URL of a CometBFT RPC endpoint and the origin to dial it with.
+type wsEndpoint struct {
+	url    string
+	origin string
+}
+
+// NewBlockSubscriber creates a new subscriber for the CometBFT RPC endpoints at rpcURLs,
+// used in turn: the subscription starts on the first and every reconnect moves on to
+// the next one, so a node that is down does not stall it. http(s) URLs are converted
+// to ws(s) and the /websocket path is appended.
+func NewBlockSubscriber(rpcURLs ...string) (*BlockSubscriber, error) {
+	if len(rpcURLs) == 0 {
+		return nil, fmt.Errorf("no rpc url to subscribe to")
+	}
+	endpoints := make([]wsEndpoint, len(rpcURLs))
+	for i, rpcURL := range rpcURLs {
+		u, err := url.Parse(rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rpc url: %w", err)
+		}
+		origin := *u
+		switch u.Scheme {
+		case "http", "ws":
+			u.Scheme, origin.Scheme = "ws", "http"
+		case "https", "wss":
+			u.Scheme, origin.Scheme = "wss", "https"
+		default:
+			return nil, fmt.Errorf("unsupported rpc url scheme %q", u.Scheme)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/websocket"
+		origin.Path = ""
+		endpoints[i] = wsEndpoint{url: u.String(), origin: origin.String()}
+	}
+
+	return &BlockSubscriber{
+		endpoints:   endpoints,
+		MinBackoff:  time.Second,
+		MaxBackoff:  time.Minute,
+		ReadTimeout: time.Minute,
+	}, nil
+}
+
+var _ SubscriberInterface = (*BlockSubscriber)(nil)
+
+const newBlockQuery = "tm.event='NewBlock'"
+
+type subscribeRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	ID      int               `json:"id"`
+	Params  map[string]string `json:"params"`
+}
+
+type newBlockEvent struct {
+	Data struct {
+		Value struct {
+			Block Block `json:"block"`
+		} `json:"value"`
+	} `json:"data"`
+}
+
+// Subscribe returns a channel that receives the height of every new block.
+// The channel is closed when ctx is done. While the websocket is down no heights
+// are delivered, so callers should keep polling as a fallback.
+func (s *BlockSubscriber) Subscribe(ctx context.Context) <-chan int64 {
+	heights := make(chan int64)
+
+	go func() {
+		defer close(heights)
+
+		backoff := s.MinBackoff
+		for i := 0; ; i = (i + 1) % len(s.endpoints) {
+			endpoint := s.endpoints[i]
+			received, err := s.subscribeOnce(ctx, endpoint, heights)
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				backoff = s.MinBackoff
+			}
+			next := s.endpoints[(i+1)%len(s.endpoints)]
+			xlog.Warn("block subscription dropped, falling back to polling until reconnected", "url", endpoint.url, "next_url", next.url, "retry_in", backoff, "err", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, s.MaxBackoff)
+		}
+	}()
+
+	return heights
+}
+
+// subscribeOnce runs a single websocket session until it fails. It reports whether
+// any block was received, which is used to reset the backoff.
+func (s *BlockSubscriber) subscribeOnce(ctx context.Context, endpoint wsEndpoint, heights chan<- int64) (bool, error) {
+	wsConfig, err := websocket.NewConfig(endpoint.url, endpoint.origin)
+	if err != nil {
+		return false, fmt.Errorf("failed to create websocket config: %w", err)
+	}
+	conn, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to dial websocket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Unblock Receive when the context is cancelled.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	req := subscribeRequest{
+		JSONRPC: "2.0",
+		Method:  "subscribe",
+		ID:      1,
+		Params:  map[string]string{"query": newBlockQuery},
+	}
+	if err := websocket.JSON.Send(conn, req); err != nil {
+		return false, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	xlog.Info("subscribed to new blocks", "url", endpoint.url)
+
+	received := false
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(s.ReadTimeout)); err != nil {
+			return received, fmt.Errorf("failed to set read deadline: %w", err)
+		}
+
+		var msg rpcResponse
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return received, fmt.Errorf("failed to receive event: %w", err)
+		}
+		if msg.Error != nil {
+			return received, fmt.Errorf("rpc error %d: %s: %s", msg.Error.Code, msg.Error.Message, msg.Error.Data)
+		}
+
+		if len(msg.Result) == 0 {
+			continue
+		}
+		var event newBlockEvent
+		if err := json.Unmarshal(msg.Result, &event); err != nil {
+			return received, fmt.Errorf("failed to decode event: %w", err)
+		}
+		// The subscription confirmation carries an empty result.
+		if event.Data.Value.Block.Header.Height == "" {
+			continue
+		}
+		height, err := parseHeight(event.Data.Value.Block.Header.Height)
+		if err != nil {
+			return received, err
+		}
+
+		received = true
+		select {
+		case heights <- height:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
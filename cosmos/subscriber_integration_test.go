@@ -0,0 +1,106 @@
+package cosmos_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+)
+
+var _ = Describe("BlockSubscriber Integration", func() {
+	var (
+		server      *httptest.Server
+		connections atomic.Int32
+		ctx         context.Context
+		cancel      context.CancelFunc
+	)
+
+	// newBlockServer serves a CometBFT-like websocket that sends blocks starting at
+	// startHeight and then drops the connection.
+	newBlockServer := func(blocksPerConn int) *httptest.Server {
+		return httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+			n := connections.Add(1)
+
+			var req map[string]any
+			Expect(websocket.JSON.Receive(conn, &req)).To(Succeed())
+			Expect(req["method"]).To(Equal("subscribe"))
+			Expect(req["params"]).To(HaveKeyWithValue("query", "tm.event='NewBlock'"))
+
+			Expect(websocket.Message.Send(conn, `{"jsonrpc":"2.0","id":1,"result":{}}`)).To(Succeed())
+			for i := range blocksPerConn {
+				height := int(n)*100 + i
+				Expect(websocket.Message.Send(conn, fmt.Sprintf(`{
+					"jsonrpc": "2.0",
+					"id": 1,
+					"result": {
+						"query": "tm.event='NewBlock'",
+						"data": {"type": "tendermint/event/NewBlock", "value": {"block": {"header": {"height": "%d"}}}}
+					}
+				}`, height))).To(Succeed())
+			}
+		}))
+	}
+
+	BeforeEach(func() {
+		connections.Store(0)
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+		server.Close()
+	})
+
+	It("should deliver block heights and reconnect after the connection drops", func() {
+		server = newBlockServer(2)
+		subscriber, err := cosmos.NewBlockSubscriber(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		subscriber.MinBackoff = time.Millisecond
+		subscriber.MaxBackoff = 10 * time.Millisecond
+
+		heights := subscriber.Subscribe(ctx)
+		Eventually(heights).Should(Receive(BeEquivalentTo(100)))
+		Eventually(heights).Should(Receive(BeEquivalentTo(101)))
+		// The server closed the first connection; the subscriber must come back.
+		Eventually(heights).Should(Receive(BeEquivalentTo(200)))
+		Expect(connections.Load()).To(BeNumerically(">=", 2))
+	})
+
+	It("should move on to the next endpoint when reconnecting", func() {
+		down := newBlockServer(1)
+		server = newBlockServer(1)
+		subscriber, err := cosmos.NewBlockSubscriber(down.URL, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		subscriber.MinBackoff = time.Millisecond
+		subscriber.MaxBackoff = 10 * time.Millisecond
+
+		heights := subscriber.Subscribe(ctx)
+		Eventually(heights).Should(Receive(BeEquivalentTo(100)))
+		// The first endpoint goes down for good; only the second one can deliver blocks.
+		down.Close()
+		Eventually(heights).Should(Receive(BeNumerically(">=", 200)))
+	})
+
+	It("should close the channel when the context is cancelled", func() {
+		server = newBlockServer(0)
+		subscriber, err := cosmos.NewBlockSubscriber(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		heights := subscriber.Subscribe(ctx)
+		cancel()
+		Eventually(heights).Should(BeClosed())
+	})
+
+	It("should reject unsupported URL schemes", func() {
+		server = newBlockServer(0)
+		_, err := cosmos.NewBlockSubscriber("ftp://localhost:26657")
+		Expect(err).To(HaveOccurred())
+	})
+})
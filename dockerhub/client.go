@@ -8,12 +8,16 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/manifest"
 )
 
 // ClientInterface defines the methods to interact with DockerHub.
 type ClientInterface interface {
 	RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error
 	TagExists(ctx context.Context, repoPath, tag string) (bool, error)
+	ManifestDigest(ctx context.Context, repoPath, tag string) (string, error)
 }
 
 // Client for interacting with the DockerHub API.
@@ -30,7 +34,7 @@ func NewClient(user, password string, httpClient *http.Client) *Client {
 	return &Client{
 		user:            user,
 		password:        password,
-		httpClient:      httpClient,
+		httpClient:      metrics.InstrumentClient("registry", httpClient),
 		AuthBaseURL:     "https://auth.docker.io",
 		RegistryBaseURL: "https://registry-1.docker.io",
 	}
@@ -81,7 +85,7 @@ func (c *Client) TagExists(ctx context.Context, repoPath, tag string) (bool, err
 		return false, fmt.Errorf("failed to create manifest head request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, application/vnd.docker.distribution.manifest.list.v2+json")
+	req.Header.Set("Accept", manifest.Accept)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -99,6 +103,44 @@ func (c *Client) TagExists(ctx context.Context, repoPath, tag string) (bool, err
 	return false, fmt.Errorf("unexpected status code when checking tag: %s", resp.Status)
 }
 
+// ManifestDigest returns the digest of the manifest a tag points to.
+func (c *Client) ManifestDigest(ctx context.Context, repoPath, tag string) (string, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repoPath)
+	token, err := c.getBearerToken(ctx, scope)
+	if err != nil {
+		return "", fmt.Errorf("failed to get auth token: %w", err)
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.RegistryBaseURL, repoPath, tag)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create manifest get request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", manifest.Accept)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to get manifest, status: %s, body: %s", resp.Status, string(body))
+	}
+
+	manifestBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest body: %w", err)
+	}
+	digest := manifest.Digest(manifestBody)
+	if err := manifest.VerifyDigest(digest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return "", fmt.Errorf("manifest %s: %w", tag, err)
+	}
+	return digest, nil
+}
+
 // RetagImage retags a Docker image from a source tag to a target tag.
 func (c *Client) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error {
 	scope := fmt.Sprintf("repository:%s:pull,push", repoPath)
@@ -113,7 +155,7 @@ func (c *Client) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag
 		return fmt.Errorf("failed to create manifest get request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, application/vnd.docker.distribution.manifest.list.v2+json")
+	req.Header.Set("Accept", manifest.Accept)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -126,15 +168,24 @@ func (c *Client) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag
 		return fmt.Errorf("failed to get manifest, status: %s, body: %s", resp.Status, string(body))
 	}
 
-	manifest, err := io.ReadAll(resp.Body)
+	manifestBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read manifest body: %w", err)
 	}
-	contentType := resp.Header.Get("Content-Type")
+	// The digest is computed over the exact bytes, which we PUT back unchanged so the
+	// target tag points at the very same manifest.
+	sourceDigest := manifest.Digest(manifestBody)
+	if err := manifest.VerifyDigest(sourceDigest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return fmt.Errorf("source manifest %s: %w", sourceTag, err)
+	}
+	contentType, err := manifest.MediaType(manifestBody, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("failed to determine manifest media type: %w", err)
+	}
 
 	// Now PUT the manifest with the new tag
 	targetURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.RegistryBaseURL, repoPath, targetTag)
-	req, err = http.NewRequestWithContext(ctx, http.MethodPut, targetURL, bytes.NewBuffer(manifest))
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, targetURL, bytes.NewReader(manifestBody))
 	if err != nil {
 		return fmt.Errorf("failed to create manifest put request: %w", err)
 	}
@@ -151,6 +202,9 @@ func (c *Client) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("failed to put manifest, status: %s, body: %s", resp.Status, string(body))
 	}
+	if err := manifest.VerifyDigest(sourceDigest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return fmt.Errorf("target manifest %s: %w", targetTag, err)
+	}
 
 	return nil
 }
@@ -2,7 +2,10 @@ package dockerhub_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 
@@ -72,6 +75,31 @@ var _ = Describe("Client Integration", func() {
 		})
 	})
 
+	Describe("ManifestDigest", func() {
+		It("should return the digest of the exact manifest bytes", func() {
+			mux.HandleFunc("/v2/my/repo/manifests/digest-tag", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodGet))
+				w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+				_, err := fmt.Fprint(w, `{"schemaVersion":2}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			digest, err := client.ManifestDigest(ctx, "my/repo", "digest-tag")
+			Expect(err).NotTo(HaveOccurred())
+			sum := sha256.Sum256([]byte(`{"schemaVersion":2}`))
+			Expect(digest).To(Equal("sha256:" + hex.EncodeToString(sum[:])))
+		})
+
+		It("should return an error if the tag does not exist", func() {
+			mux.HandleFunc("/v2/my/repo/manifests/missing", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			_, err := client.ManifestDigest(ctx, "my/repo", "missing")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("RetagImage", func() {
 		It("should successfully get and put the manifest to retag an image", func() {
 			const manifestContent = `{"hello":"world"}`
@@ -101,6 +129,50 @@ var _ = Describe("Client Integration", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		It("should negotiate OCI media types and preserve an OCI image index byte for byte", func() {
+			// Deliberately unusual formatting: any re-encoding would change the digest.
+			const indexContent = "{\n  \"schemaVersion\": 2,\n  \"mediaType\": \"application/vnd.oci.image.index.v1+json\",\n  \"manifests\": []\n}"
+			var putDigest string
+
+			mux.HandleFunc("/v2/my/repo/manifests/oci-source", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.index.v1+json"))
+				Expect(r.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.manifest.v1+json"))
+				w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+				_, err := fmt.Fprint(w, indexContent)
+				Expect(err).NotTo(HaveOccurred())
+			})
+			mux.HandleFunc("/v2/my/repo/manifests/oci-target", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/vnd.oci.image.index.v1+json"))
+				body, err := io.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal(indexContent))
+				sum := sha256.Sum256(body)
+				putDigest = "sha256:" + hex.EncodeToString(sum[:])
+				w.Header().Set("Docker-Content-Digest", putDigest)
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			err := client.RetagImage(ctx, "my/repo", "oci-source", "oci-target")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(putDigest).NotTo(BeEmpty())
+		})
+
+		It("should return an error if the registry reports a different digest for the target", func() {
+			mux.HandleFunc("/v2/my/repo/manifests/digest-source", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				_, err := fmt.Fprint(w, `{"schemaVersion":2}`)
+				Expect(err).NotTo(HaveOccurred())
+			})
+			mux.HandleFunc("/v2/my/repo/manifests/digest-target", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Docker-Content-Digest", "sha256:0000000000000000000000000000000000000000000000000000000000000000")
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			err := client.RetagImage(ctx, "my/repo", "digest-source", "digest-target")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("digest mismatch"))
+		})
+
 		It("should return an error if getting the source manifest fails", func() {
 			mux.HandleFunc("/v2/my/repo/manifests/source-tag-fail", func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusNotFound)
@@ -2,7 +2,9 @@ package health
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/gopher-lab/gopher-updater/cosmos"
 	"github.com/gopher-lab/gopher-updater/dockerhub"
@@ -10,35 +12,68 @@ import (
 
 // Checker performs readiness checks for the application.
 type Checker struct {
-	cosmosClient    cosmos.ClientInterface
+	mu              sync.RWMutex
+	blockClient     cosmos.BlockClient
 	dockerhubClient dockerhub.ClientInterface
 	repoPath        string
 }
 
-// NewChecker creates a new health checker.
+// NewChecker creates a new health checker. blockClient may be either the REST
+// or the CometBFT RPC client.
 func NewChecker(
-	cosmosClient cosmos.ClientInterface,
+	blockClient cosmos.BlockClient,
 	dockerhubClient dockerhub.ClientInterface,
 	repoPath string,
 ) *Checker {
 	return &Checker{
-		cosmosClient:    cosmosClient,
+		blockClient:     blockClient,
 		dockerhubClient: dockerhubClient,
 		repoPath:        repoPath,
 	}
 }
 
+// Reload replaces the clients and repository the checks use, e.g. after the
+// configuration was reloaded. Checks in progress complete with the previous ones.
+func (c *Checker) Reload(
+	blockClient cosmos.BlockClient,
+	dockerhubClient dockerhub.ClientInterface,
+	repoPath string,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockClient = blockClient
+	c.dockerhubClient = dockerhubClient
+	c.repoPath = repoPath
+}
+
 // Ready checks if the application is ready to serve traffic.
 // It verifies connectivity to both the Cosmos chain and DockerHub.
 func (c *Checker) Ready(ctx context.Context) error {
+	c.mu.RLock()
+	blockClient, dockerhubClient, repoPath := c.blockClient, c.dockerhubClient, c.repoPath
+	c.mu.RUnlock()
+
 	// Check Cosmos connection
-	if _, err := c.cosmosClient.GetLatestBlockHeight(ctx); err != nil {
+	if _, err := blockClient.GetLatestBlockHeight(ctx); err != nil {
 		return fmt.Errorf("cosmos connection failed: %w", err)
 	}
 
+	// A node that is still catching up reports a stale height. Clients that may or may
+	// not be able to tell, like a FailoverClient, report errors.ErrUnsupported.
+	if statusClient, ok := blockClient.(cosmos.StatusClient); ok {
+		status, err := statusClient.GetStatus(ctx)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+		case err != nil:
+			return fmt.Errorf("cosmos status check failed: %w", err)
+		case status.SyncInfo.CatchingUp:
+			return fmt.Errorf("cosmos node is still catching up")
+		}
+	}
+
 	// Check DockerHub connection and authentication.
 	// We check for a tag that is highly unlikely to exist.
-	if _, err := c.dockerhubClient.TagExists(ctx, c.repoPath, "readiness-check"); err != nil {
+	if _, err := dockerhubClient.TagExists(ctx, repoPath, "readiness-check"); err != nil {
 		return fmt.Errorf("dockerhub connection failed: %w", err)
 	}
 
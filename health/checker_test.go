@@ -3,8 +3,10 @@ package health_test
 import (
 	"context"
 	"errors"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -70,6 +72,69 @@ var _ = Describe("Checker", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("dockerhub connection failed"))
 	})
+
+	Context("with a CometBFT block client", func() {
+		var mockStatusClient *MockStatusClient
+
+		BeforeEach(func() {
+			mockStatusClient = &MockStatusClient{}
+			checker = health.NewChecker(mockStatusClient, mockDockerHubClient, "my/repo")
+		})
+
+		It("should return no error when the node is synced", func() {
+			mockStatusClient.getStatusFunc = func(ctx context.Context) (*cosmos.NodeStatus, error) {
+				return &cosmos.NodeStatus{}, nil
+			}
+
+			err := checker.Ready(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error when the node is catching up", func() {
+			mockStatusClient.getStatusFunc = func(ctx context.Context) (*cosmos.NodeStatus, error) {
+				return &cosmos.NodeStatus{SyncInfo: cosmos.SyncInfo{CatchingUp: true}}, nil
+			}
+
+			err := checker.Ready(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("catching up"))
+		})
+	})
+
+	Context("with several endpoints", func() {
+		// newFailoverChecker checks a FailoverClient over two nodes using blockClient
+		// for blocks, as set up for several RPC endpoints.
+		newFailoverChecker := func(blockClient cosmos.BlockClient) *health.Checker {
+			endpoints := make([]cosmos.Endpoint, 2)
+			for i := range endpoints {
+				client := cosmos.NewClient("http://node.invalid", nil)
+				client.BlockClient = blockClient
+				endpoints[i] = cosmos.Endpoint{Name: strconv.Itoa(i), Client: client}
+			}
+			failoverClient := cosmos.NewFailoverClient(endpoints, time.Minute, 0)
+			return health.NewChecker(failoverClient, mockDockerHubClient, "my/repo")
+		}
+
+		It("should return an error when the CometBFT node is catching up", func() {
+			checker = newFailoverChecker(&MockStatusClient{getStatusFunc: func(ctx context.Context) (*cosmos.NodeStatus, error) {
+				return &cosmos.NodeStatus{SyncInfo: cosmos.SyncInfo{CatchingUp: true}}, nil
+			}})
+
+			err := checker.Ready(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("catching up"))
+		})
+
+		It("should return no error when the CometBFT node is synced", func() {
+			checker = newFailoverChecker(&MockStatusClient{})
+			Expect(checker.Ready(ctx)).To(Succeed())
+		})
+
+		It("should skip the sync check for block clients that cannot report it", func() {
+			checker = newFailoverChecker(mockCosmosClient)
+			Expect(checker.Ready(ctx)).To(Succeed())
+		})
+	})
 })
 
 // --- Mock Implementations ---
@@ -80,6 +145,9 @@ var _ = Describe("Checker", func() {
 type MockCosmosClient struct {
 	getUpgradePlansFunc      func(ctx context.Context) ([]cosmos.Plan, error)
 	getLatestBlockHeightFunc func(ctx context.Context) (int64, error)
+	getBlockHeaderFunc       func(ctx context.Context, height int64) (*cosmos.BlockHeader, error)
+	getCurrentPlanFunc       func(ctx context.Context) (*cosmos.Plan, error)
+	getAppliedPlanHeightFunc func(ctx context.Context, name string) (int64, error)
 }
 
 func (m *MockCosmosClient) GetUpgradePlans(ctx context.Context) ([]cosmos.Plan, error) {
@@ -96,11 +164,46 @@ func (m *MockCosmosClient) GetLatestBlockHeight(ctx context.Context) (int64, err
 	return 0, nil
 }
 
+func (m *MockCosmosClient) GetBlockHeader(ctx context.Context, height int64) (*cosmos.BlockHeader, error) {
+	if m.getBlockHeaderFunc != nil {
+		return m.getBlockHeaderFunc(ctx, height)
+	}
+	return &cosmos.BlockHeader{Height: strconv.FormatInt(height, 10)}, nil
+}
+
+func (m *MockCosmosClient) GetCurrentPlan(ctx context.Context) (*cosmos.Plan, error) {
+	if m.getCurrentPlanFunc != nil {
+		return m.getCurrentPlanFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockCosmosClient) GetAppliedPlanHeight(ctx context.Context, name string) (int64, error) {
+	if m.getAppliedPlanHeightFunc != nil {
+		return m.getAppliedPlanHeightFunc(ctx, name)
+	}
+	return 0, nil
+}
+
+// MockStatusClient is a mock CometBFT block client that also reports node status.
+type MockStatusClient struct {
+	MockCosmosClient
+	getStatusFunc func(ctx context.Context) (*cosmos.NodeStatus, error)
+}
+
+func (m *MockStatusClient) GetStatus(ctx context.Context) (*cosmos.NodeStatus, error) {
+	if m.getStatusFunc != nil {
+		return m.getStatusFunc(ctx)
+	}
+	return &cosmos.NodeStatus{}, nil
+}
+
 // MockDockerHubClient is a mock implementation of the DockerHub client for testing.
 type MockDockerHubClient struct {
-	mu            sync.Mutex
-	retagCalls    []any
-	tagExistsFunc func(ctx context.Context, repoPath, tag string) (bool, error)
+	mu                 sync.Mutex
+	retagCalls         []any
+	tagExistsFunc      func(ctx context.Context, repoPath, tag string) (bool, error)
+	manifestDigestFunc func(ctx context.Context, repoPath, tag string) (string, error)
 }
 
 func (m *MockDockerHubClient) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error {
@@ -110,6 +213,13 @@ func (m *MockDockerHubClient) RetagImage(ctx context.Context, repoPath, sourceTa
 	return nil
 }
 
+func (m *MockDockerHubClient) ManifestDigest(ctx context.Context, repoPath, tag string) (string, error) {
+	if m.manifestDigestFunc != nil {
+		return m.manifestDigestFunc(ctx, repoPath, tag)
+	}
+	return "sha256:" + tag, nil
+}
+
 func (m *MockDockerHubClient) TagExists(ctx context.Context, repoPath, tag string) (bool, error) {
 	if m.tagExistsFunc != nil {
 		return m.tagExistsFunc(ctx, repoPath, tag)
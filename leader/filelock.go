@@ -0,0 +1,87 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// FileLock is a Lock backed by an exclusive lock on a file, for replicas running on
+// the same host. The lock is held for as long as the process keeps the file open, so
+// it is released by the operating system if the holder dies, and ttl is not used.
+// The file holds the identity of the holder.
+type FileLock struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileLock creates a Lock on the file at path, created on first use.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+var _ Lock = (*FileLock)(nil)
+
+// TryAcquire locks the file for identity unless another process holds it, and returns
+// the identity of the holder.
+func (l *FileLock) TryAcquire(_ context.Context, identity string, _ time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		return identity, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		holder, _ := io.ReadAll(io.LimitReader(file, 256))
+		_ = file.Close()
+		if errors.Is(err, errLocked) {
+			return strings.TrimSpace(string(holder)), nil
+		}
+		return "", fmt.Errorf("failed to lock %s: %w", l.path, err)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(identity+"\n"), 0)
+	}
+	l.file = file
+	return identity, nil
+}
+
+// Release unlocks the file if this process holds it.
+func (l *FileLock) Release(_ context.Context, _ string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", l.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Package leader elects a single replica to promote upgrades, so several replicas can
+// run for availability without racing each other on the registry.
+package leader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// Lock is held by at most one identity at a time.
+type Lock interface {
+	// TryAcquire acquires the lock for identity, or renews it if identity already
+	// holds it, for ttl. It returns the identity of the holder, which is identity
+	// when it holds the lock, or empty if the lock is free or the holder unknown.
+	TryAcquire(ctx context.Context, identity string, ttl time.Duration) (string, error)
+	// Release gives the lock up if identity holds it.
+	Release(ctx context.Context, identity string) error
+}
+
+// Elector campaigns for a Lock and runs a function while it is the leader.
+type Elector struct {
+	lock     Lock
+	identity string
+	// leaseDuration is how long the lock is held without being renewed. The leader
+	// steps down if it could not renew the lock for renewDeadline, shorter than
+	// leaseDuration so it stops before another replica can take over.
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	mu      sync.Mutex
+	leader  string
+	leading bool
+}
+
+// NewElector creates an Elector for identity, which must be unique among replicas.
+func NewElector(lock Lock, identity string, leaseDuration time.Duration) *Elector {
+	return &Elector{
+		lock:          lock,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewDeadline: leaseDuration * 2 / 3,
+		retryPeriod:   leaseDuration / 5,
+	}
+}
+
+// Identity returns the identity the Elector campaigns with.
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// Leader reports whether this replica is the leader, and the identity of the leader
+// as last seen, which is empty if unknown.
+func (e *Elector) Leader() (bool, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading, e.leader
+}
+
+// Run campaigns for the lock until ctx is done. Whenever it becomes the leader, it
+// calls lead with a context that is cancelled as soon as leadership is lost, and
+// waits for lead to return before campaigning again. Run returns when ctx is done
+// or when lead returns on its own, releasing the lock if it holds it.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for {
+		if !e.campaign(ctx) {
+			return
+		}
+		xlog.Info("became the leader", "identity", e.identity)
+		e.setLeading(true)
+
+		leadCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			lead(leadCtx)
+		}()
+		lost := e.renew(leadCtx, done)
+		cancel()
+		<-done
+		e.setLeading(false)
+
+		if !lost {
+			// Let another replica take over right away instead of waiting for the lock to expire.
+			releaseCtx, cancelRelease := context.WithTimeout(context.Background(), e.retryPeriod)
+			if err := e.lock.Release(releaseCtx, e.identity); err != nil {
+				xlog.Warn("failed to release leadership", "identity", e.identity, "err", err)
+			}
+			cancelRelease()
+			return
+		}
+		xlog.Warn("lost leadership", "identity", e.identity)
+	}
+}
+
+// campaign tries to acquire the lock every retryPeriod until it succeeds, and reports
+// whether it did before ctx was done.
+func (e *Elector) campaign(ctx context.Context) bool {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+	for {
+		holder, err := e.lock.TryAcquire(ctx, e.identity, e.leaseDuration)
+		switch {
+		case err != nil:
+			xlog.Warn("failed to acquire leadership", "identity", e.identity, "err", err)
+		case holder == e.identity:
+			return true
+		default:
+			if e.setLeader(holder) {
+				xlog.Info("waiting for leadership", "identity", e.identity, "leader", holder)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew renews the lock every retryPeriod until ctx is done or lead returns, and
+// reports whether leadership was lost: another replica holds the lock, or it could
+// not be renewed for renewDeadline. A renewal that hangs is given up on at the
+// deadline, so the leader still steps down before another replica can take over.
+func (e *Elector) renew(ctx context.Context, done <-chan struct{}) bool {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+
+		remaining := e.renewDeadline - time.Since(renewed)
+		if remaining <= 0 {
+			xlog.Warn("failed to renew leadership in time", "identity", e.identity)
+			return true
+		}
+		renewCtx, cancel := context.WithTimeout(ctx, remaining)
+		holder, err := e.lock.TryAcquire(renewCtx, e.identity, e.leaseDuration)
+		cancel()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return false
+		case err != nil:
+			xlog.Warn("failed to renew leadership", "identity", e.identity, "err", err)
+			if time.Since(renewed) >= e.renewDeadline {
+				return true
+			}
+		case holder != e.identity:
+			e.setLeader(holder)
+			return true
+		default:
+			renewed = time.Now()
+		}
+	}
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leading = leading
+	if leading {
+		e.leader = e.identity
+		metrics.Leader.Set(1)
+	} else {
+		e.leader = ""
+		metrics.Leader.Set(0)
+	}
+}
+
+// setLeader records holder as the leader and reports whether it changed.
+func (e *Elector) setLeader(holder string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	changed := e.leader != holder
+	e.leader = holder
+	return changed
+}
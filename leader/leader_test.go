@@ -0,0 +1,186 @@
+package leader_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/leader"
+)
+
+// fakeLock is a Lock whose holder and failures are set by the test.
+type fakeLock struct {
+	mu       sync.Mutex
+	holder   string
+	err      error
+	hang     bool
+	released []string
+}
+
+func (f *fakeLock) TryAcquire(ctx context.Context, identity string, _ time.Duration) (string, error) {
+	f.mu.Lock()
+	if f.hang {
+		f.mu.Unlock()
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	if f.holder == "" {
+		f.holder = identity
+	}
+	return f.holder, nil
+}
+
+func (f *fakeLock) Release(_ context.Context, identity string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.holder == identity {
+		f.holder = ""
+		f.released = append(f.released, identity)
+	}
+	return nil
+}
+
+func (f *fakeLock) set(holder string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.holder, f.err = holder, err
+}
+
+func (f *fakeLock) setHang(hang bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hang = hang
+}
+
+func (f *fakeLock) releases() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.released...)
+}
+
+var _ = Describe("Elector", func() {
+	const leaseDuration = 100 * time.Millisecond
+
+	var (
+		lock    *fakeLock
+		elector *leader.Elector
+		ctx     context.Context
+		cancel  context.CancelFunc
+		// terms counts the calls to lead, leading whether one is running.
+		terms   chan struct{}
+		leading chan bool
+		done    chan struct{}
+	)
+
+	run := func(lead func(ctx context.Context)) {
+		go func() {
+			defer close(done)
+			elector.Run(ctx, lead)
+		}()
+		DeferCleanup(func() {
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+	}
+
+	isLeader := func() bool {
+		leader, _ := elector.Leader()
+		return leader
+	}
+
+	holder := func() string {
+		_, holder := elector.Leader()
+		return holder
+	}
+
+	// lead records every term and runs until it is cancelled.
+	lead := func(ctx context.Context) {
+		terms <- struct{}{}
+		leading <- true
+		<-ctx.Done()
+		leading <- false
+	}
+
+	BeforeEach(func() {
+		lock = &fakeLock{}
+		elector = leader.NewElector(lock, "pod-a", leaseDuration)
+		ctx, cancel = context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		terms = make(chan struct{}, 10)
+		leading = make(chan bool, 10)
+		done = make(chan struct{})
+	})
+
+	It("should lead until the context is done and then release the lock", func() {
+		run(lead)
+		Eventually(leading).Should(Receive(BeTrue()))
+		Expect(isLeader()).To(BeTrue())
+		Expect(holder()).To(Equal("pod-a"))
+		Expect(elector.Identity()).To(Equal("pod-a"))
+
+		cancel()
+		Eventually(leading).Should(Receive(BeFalse()))
+		Eventually(done).Should(BeClosed())
+		Expect(lock.releases()).To(Equal([]string{"pod-a"}))
+		Expect(isLeader()).To(BeFalse())
+	})
+
+	It("should wait while another replica leads", func() {
+		lock.set("pod-b", nil)
+		run(lead)
+		Eventually(holder).Should(Equal("pod-b"))
+		Consistently(terms, 3*leaseDuration).ShouldNot(Receive())
+
+		lock.set("", nil)
+		Eventually(leading).Should(Receive(BeTrue()))
+	})
+
+	It("should stop leading when another replica takes the lock over", func() {
+		run(lead)
+		Eventually(leading).Should(Receive(BeTrue()))
+
+		lock.set("pod-b", nil)
+		Eventually(leading).Should(Receive(BeFalse()))
+		Eventually(isLeader).Should(BeFalse())
+		Expect(holder()).To(Equal("pod-b"))
+		Expect(done).NotTo(BeClosed())
+
+		lock.set("", nil)
+		Eventually(leading).Should(Receive(BeTrue()))
+		Expect(terms).To(HaveLen(2))
+	})
+
+	It("should stop leading when the lock cannot be renewed in time", func() {
+		run(lead)
+		Eventually(leading).Should(Receive(BeTrue()))
+
+		lock.set("pod-a", errors.New("api server unavailable"))
+		Eventually(leading, 2*leaseDuration).Should(Receive(BeFalse()))
+		Eventually(isLeader).Should(BeFalse())
+		Expect(lock.releases()).To(BeEmpty())
+	})
+
+	It("should stop leading when renewing the lock hangs", func() {
+		run(lead)
+		Eventually(leading).Should(Receive(BeTrue()))
+
+		lock.setHang(true)
+		Eventually(leading, 2*leaseDuration).Should(Receive(BeFalse()))
+		Eventually(isLeader).Should(BeFalse())
+		Expect(done).NotTo(BeClosed())
+	})
+
+	It("should return and release the lock when lead returns", func() {
+		run(func(context.Context) {})
+		Eventually(done).Should(BeClosed())
+		Expect(lock.releases()).To(Equal([]string{"pod-a"}))
+	})
+})
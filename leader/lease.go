@@ -0,0 +1,161 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
+)
+
+// microTimeFormat is how the Kubernetes API formats the MicroTime fields of a Lease.
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// LeaseLock is a Lock backed by a Kubernetes coordination.k8s.io/v1 Lease, created
+// on first use. Updates use the Lease's resourceVersion, so of two replicas racing
+// for it, only one wins.
+//
+// Whether a Lease held by another replica expired is decided by how long its record
+// has not changed as observed locally, never by comparing clocks across replicas.
+type LeaseLock struct {
+	client    *kube.Client
+	namespace string
+	name      string
+
+	mu         sync.Mutex
+	observed   leaseSpec
+	observedAt time.Time
+}
+
+// NewLeaseLock creates a Lock for the Lease name in namespace.
+func NewLeaseLock(client *kube.Client, namespace, name string) *LeaseLock {
+	return &LeaseLock{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+var _ Lock = (*LeaseLock)(nil)
+
+type lease struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Metadata   kube.ObjectMeta `json:"metadata"`
+	Spec       leaseSpec       `json:"spec"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// TryAcquire acquires the Lease for identity if it is free, expired or already held
+// by identity, and returns the identity of its holder.
+func (l *LeaseLock) TryAcquire(ctx context.Context, identity string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	spec := leaseSpec{
+		HolderIdentity:       identity,
+		LeaseDurationSeconds: max(int(ttl.Round(time.Second)/time.Second), 1),
+		AcquireTime:          now.UTC().Format(microTimeFormat),
+		RenewTime:            now.UTC().Format(microTimeFormat),
+	}
+
+	var current lease
+	err := l.client.Get(ctx, l.objectPath(), &current)
+	if errors.Is(err, kube.ErrNotFound) {
+		current = lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   kube.ObjectMeta{Name: l.name, Namespace: l.namespace},
+			Spec:       spec,
+		}
+		err = l.client.Create(ctx, l.collectionPath(), current, nil)
+		if errors.Is(err, kube.ErrConflict) {
+			// Another replica created it first.
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create lease %s/%s: %w", l.namespace, l.name, err)
+		}
+		l.observe(spec, now)
+		return identity, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get lease %s/%s: %w", l.namespace, l.name, err)
+	}
+
+	if current.Spec != l.observed {
+		l.observe(current.Spec, now)
+	}
+	holder := current.Spec.HolderIdentity
+	expired := now.After(l.observedAt.Add(time.Duration(current.Spec.LeaseDurationSeconds) * time.Second))
+	if holder != "" && holder != identity && !expired {
+		return holder, nil
+	}
+
+	spec.LeaseTransitions = current.Spec.LeaseTransitions
+	if holder == identity {
+		spec.AcquireTime = current.Spec.AcquireTime
+	} else {
+		spec.LeaseTransitions++
+	}
+	current.Spec = spec
+	err = l.client.Update(ctx, l.objectPath(), current, nil)
+	if errors.Is(err, kube.ErrConflict) {
+		// Another replica renewed or took it over in between.
+		return holder, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to update lease %s/%s: %w", l.namespace, l.name, err)
+	}
+	l.observe(spec, now)
+	return identity, nil
+}
+
+// Release clears the holder of the Lease if identity holds it, so another replica
+// can acquire it without waiting for it to expire.
+func (l *LeaseLock) Release(ctx context.Context, identity string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var current lease
+	if err := l.client.Get(ctx, l.objectPath(), &current); err != nil {
+		if errors.Is(err, kube.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get lease %s/%s: %w", l.namespace, l.name, err)
+	}
+	if current.Spec.HolderIdentity != identity {
+		return nil
+	}
+
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	current.Spec.RenewTime = time.Now().UTC().Format(microTimeFormat)
+	if err := l.client.Update(ctx, l.objectPath(), current, nil); err != nil {
+		return fmt.Errorf("failed to release lease %s/%s: %w", l.namespace, l.name, err)
+	}
+	return nil
+}
+
+func (l *LeaseLock) observe(spec leaseSpec, now time.Time) {
+	l.observed = spec
+	l.observedAt = now
+}
+
+func (l *LeaseLock) collectionPath() string {
+	return fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.namespace)
+}
+
+func (l *LeaseLock) objectPath() string {
+	return fmt.Sprintf("%s/%s", l.collectionPath(), l.name)
+}
@@ -0,0 +1,189 @@
+package leader_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/leader"
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
+)
+
+// fakeLeaseAPI serves a single Lease the way the Kubernetes API server does,
+// including resourceVersion conflicts.
+type fakeLeaseAPI struct {
+	mu              sync.Mutex
+	lease           map[string]any
+	resourceVersion int
+	conflicts       int
+}
+
+func (f *fakeLeaseAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const collection = "/apis/coordination.k8s.io/v1/namespaces/ns/leases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == collection+"/leader":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		Expect(json.NewEncoder(w).Encode(f.lease)).To(Succeed())
+	case r.Method == http.MethodPost && r.URL.Path == collection:
+		if f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.save(f.decode(r))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.URL.Path == collection+"/leader":
+		lease := f.decode(r)
+		if f.conflicts > 0 || lease["metadata"].(map[string]any)["resourceVersion"] != strconv.Itoa(f.resourceVersion) {
+			f.conflicts--
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.save(lease)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeLeaseAPI) decode(r *http.Request) map[string]any {
+	var lease map[string]any
+	Expect(json.NewDecoder(r.Body).Decode(&lease)).To(Succeed())
+	return lease
+}
+
+func (f *fakeLeaseAPI) save(lease map[string]any) {
+	f.resourceVersion++
+	lease["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(f.resourceVersion)
+	f.lease = lease
+}
+
+// spec returns the spec of the Lease.
+func (f *fakeLeaseAPI) spec() map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lease["spec"].(map[string]any)
+}
+
+var _ = Describe("Lock", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	// lockBehaviour runs the contract every Lock implementation must satisfy.
+	lockBehaviour := func(newLock func() leader.Lock) {
+		It("should be held by a single identity", func() {
+			a, b := newLock(), newLock()
+			Expect(a.TryAcquire(ctx, "a", time.Minute)).To(Equal("a"))
+			Expect(b.TryAcquire(ctx, "b", time.Minute)).To(Equal("a"))
+			Expect(a.TryAcquire(ctx, "a", time.Minute)).To(Equal("a"))
+		})
+
+		It("should be acquired by another identity once released", func() {
+			a, b := newLock(), newLock()
+			Expect(a.TryAcquire(ctx, "a", time.Minute)).To(Equal("a"))
+			Expect(a.Release(ctx, "a")).To(Succeed())
+			Expect(b.TryAcquire(ctx, "b", time.Minute)).To(Equal("b"))
+		})
+
+		It("should ignore releases by another identity", func() {
+			a, b := newLock(), newLock()
+			Expect(a.TryAcquire(ctx, "a", time.Minute)).To(Equal("a"))
+			Expect(b.Release(ctx, "b")).To(Succeed())
+			Expect(b.TryAcquire(ctx, "b", time.Minute)).To(Equal("a"))
+		})
+	}
+
+	Describe("FileLock", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "nested", "leader.lock")
+		})
+
+		lockBehaviour(func() leader.Lock { return leader.NewFileLock(path) })
+
+		It("should record the holder in the file", func() {
+			lock := leader.NewFileLock(path)
+			Expect(lock.TryAcquire(ctx, "pod-a", time.Minute)).To(Equal("pod-a"))
+			Expect(os.ReadFile(path)).To(Equal([]byte("pod-a\n")))
+			Expect(lock.Release(ctx, "pod-a")).To(Succeed())
+		})
+	})
+
+	Describe("LeaseLock", func() {
+		var (
+			api    *fakeLeaseAPI
+			server *httptest.Server
+		)
+
+		BeforeEach(func() {
+			api = &fakeLeaseAPI{}
+			server = httptest.NewServer(api)
+			DeferCleanup(server.Close)
+		})
+
+		newLock := func() leader.Lock {
+			return leader.NewLeaseLock(kube.NewClient(server.URL, "token", server.Client()), "ns", "leader")
+		}
+
+		lockBehaviour(newLock)
+
+		It("should write the holder and the lease duration", func() {
+			Expect(newLock().TryAcquire(ctx, "pod-a", 15*time.Second)).To(Equal("pod-a"))
+			spec := api.spec()
+			Expect(spec).To(HaveKeyWithValue("holderIdentity", "pod-a"))
+			Expect(spec).To(HaveKeyWithValue("leaseDurationSeconds", BeNumerically("==", 15)))
+			Expect(spec).To(HaveKey("renewTime"))
+		})
+
+		It("should take over a lease that was not renewed for its duration", func() {
+			a, b := newLock(), newLock()
+			Expect(a.TryAcquire(ctx, "a", time.Second)).To(Equal("a"))
+			Expect(b.TryAcquire(ctx, "b", time.Second)).To(Equal("a"))
+			Eventually(func() (string, error) {
+				return b.TryAcquire(ctx, "b", time.Second)
+			}, 3*time.Second, 100*time.Millisecond).Should(Equal("b"))
+			Expect(api.spec()).To(HaveKeyWithValue("leaseTransitions", BeNumerically("==", 1)))
+
+			Expect(a.TryAcquire(ctx, "a", time.Second)).To(Equal("b"))
+		})
+
+		It("should not acquire a lease it lost a race for", func() {
+			a := newLock()
+			Expect(a.Release(ctx, "a")).To(Succeed())
+			Expect(a.TryAcquire(ctx, "a", time.Minute)).To(Equal("a"))
+			Expect(a.Release(ctx, "a")).To(Succeed())
+
+			api.mu.Lock()
+			api.conflicts = 1
+			api.mu.Unlock()
+			Expect(newLock().TryAcquire(ctx, "b", time.Minute)).To(BeEmpty())
+			Expect(newLock().TryAcquire(ctx, "b", time.Minute)).To(Equal("b"))
+		})
+
+		It("should not take over a lease that keeps being renewed", func() {
+			a, b := newLock(), newLock()
+			Expect(a.TryAcquire(ctx, "a", time.Second)).To(Equal("a"))
+			Consistently(func() (string, error) {
+				Expect(a.TryAcquire(ctx, "a", time.Second)).To(Equal("a"))
+				return b.TryAcquire(ctx, "b", time.Second)
+			}, 2*time.Second, 200*time.Millisecond).Should(Equal("a"))
+		})
+	})
+})
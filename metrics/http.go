@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// InstrumentClient returns a copy of httpClient that records the duration of every
+// request in RequestDuration under the given client label. The endpoint label is the
+// request host, so the cardinality stays bounded by the number of configured endpoints.
+func InstrumentClient(client string, httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	instrumented := *httpClient
+	instrumented.Transport = &instrumentedTransport{client: client, next: next}
+	return &instrumented
+}
+
+type instrumentedTransport struct {
+	client string
+	next   http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	RequestDuration.WithLabelValues(t.client, req.URL.Host, status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
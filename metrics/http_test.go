@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+)
+
+var _ = Describe("InstrumentClient", func() {
+	var (
+		server *httptest.Server
+		host   string
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+
+		u, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		host = u.Host
+	})
+
+	It("should observe requests by client, endpoint and status", func() {
+		client := metrics.InstrumentClient("test-status", &http.Client{})
+
+		for _, path := range []string{"/", "/", "/missing"} {
+			resp, err := client.Get(server.URL + path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+		}
+
+		Expect(sampleCount("test-status", host, "200")).To(BeEquivalentTo(2))
+		Expect(sampleCount("test-status", host, "404")).To(BeEquivalentTo(1))
+	})
+
+	It("should label transport errors as error", func() {
+		client := metrics.InstrumentClient("test-error", nil)
+		server.Close()
+
+		_, err := client.Get(server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(sampleCount("test-error", host, "error")).To(BeEquivalentTo(1))
+	})
+})
+
+func sampleCount(labels ...string) uint64 {
+	var m dto.Metric
+	Expect(metrics.RequestDuration.WithLabelValues(labels...).(prometheus.Metric).Write(&m)).To(Succeed())
+	return m.GetHistogram().GetSampleCount()
+}
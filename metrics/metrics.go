@@ -0,0 +1,141 @@
+// Package metrics defines the Prometheus metrics exported by gopher-updater.
+// They are registered with the default registry, which /metrics serves. Metrics
+// about a chain carry a target label naming the target they belong to.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gopher_updater"
+
+// Results used as the result label.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// CheckCycles counts upgrade check cycles by target and result.
+	CheckCycles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_cycles_total",
+		Help:      "Number of upgrade check cycles, by target and result.",
+	}, []string{"target", "result"})
+
+	// CheckDuration observes how long upgrade check cycles take.
+	CheckDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "check_duration_seconds",
+		Help:      "Duration of upgrade check cycles.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target"})
+
+	// LastSuccessfulCheck is the Unix time of the last check cycle that completed without error.
+	LastSuccessfulCheck = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_check_timestamp_seconds",
+		Help:      "Unix time of the last upgrade check cycle that completed without error.",
+	}, []string{"target"})
+
+	// RequestDuration observes outgoing HTTP requests by client, endpoint host and status code.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of outgoing HTTP requests, by client, endpoint and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client", "endpoint", "status"})
+
+	// Retags counts image retags by target and result.
+	Retags = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retags_total",
+		Help:      "Number of image retags, by target and result.",
+	}, []string{"target", "result"})
+
+	// Notifications counts notification deliveries by sink and result.
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Number of notification deliveries, by sink and result.",
+	}, []string{"sink", "result"})
+
+	// ChainHeight is the chain height seen during the last check.
+	ChainHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_height",
+		Help:      "Chain height seen during the last upgrade check.",
+	}, []string{"target"})
+
+	// NextUpgradeHeight is the height of the next upcoming upgrade, or 0 if there is none.
+	NextUpgradeHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "next_upgrade_height",
+		Help:      "Height of the next upcoming upgrade, or 0 if there is none.",
+	}, []string{"target"})
+
+	// BlocksRemaining is the number of blocks until the next upgrade, or 0 if there is none.
+	BlocksRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "blocks_remaining",
+		Help:      "Number of blocks until the next upcoming upgrade, or 0 if there is none.",
+	}, []string{"target"})
+
+	// UpgradeETA is the estimated number of seconds until the next upgrade, or 0 if there is none.
+	UpgradeETA = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "next_upgrade_eta_seconds",
+		Help:      "Estimated number of seconds until the next upcoming upgrade, or 0 if there is none or it cannot be estimated.",
+	}, []string{"target"})
+
+	// Paused is 1 while automatic promotion is paused through the admin API, and 0 otherwise.
+	Paused = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "promotion_paused",
+		Help:      "Whether automatic promotion is paused through the admin API.",
+	}, []string{"target"})
+
+	// AwaitingApproval is the number of reached upgrades waiting for a manual approval.
+	AwaitingApproval = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upgrades_awaiting_approval",
+		Help:      "Number of reached upgrades waiting for a manual approval.",
+	}, []string{"target"})
+
+	// Leader is 1 while this replica is the leader elected to promote upgrades, and 0 otherwise.
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this replica is the leader elected to promote upgrades.",
+	})
+
+	// ConfigReloads counts configuration reloads by result.
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of configuration reloads, by result.",
+	}, []string{"result"})
+
+	// LastSuccessfulConfigReload is the Unix time the configuration was last loaded successfully.
+	LastSuccessfulConfigReload = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_successful_reload_timestamp_seconds",
+		Help:      "Unix time the configuration was last loaded successfully, on startup or by a reload.",
+	})
+
+	// SourceImagePresent reports, per target, upcoming plan and repository, whether its source image exists (1) or not (0).
+	SourceImagePresent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "source_image_present",
+		Help:      "Whether the source image of an upcoming upgrade plan exists in the registry.",
+	}, []string{"target", "plan", "repo"})
+)
+
+// Result returns the result label for err.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
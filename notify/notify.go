@@ -0,0 +1,198 @@
+// Package notify sends upgrade lifecycle events to chat and webhook sinks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// EventType identifies an upgrade lifecycle event.
+type EventType string
+
+const (
+	// EventProposalPassed is sent the first time an upcoming upgrade plan is seen.
+	EventProposalPassed EventType = "proposal_passed"
+	// EventUpgradeIn1h is sent once when the upgrade is estimated to be less than an hour away.
+	EventUpgradeIn1h EventType = "upgrade_in_1h"
+	// EventUpgradeIn10m is sent once when the upgrade is estimated to be less than ten minutes away.
+	EventUpgradeIn10m EventType = "upgrade_in_10m"
+	// EventRetagSucceeded is sent after the target tag was promoted.
+	EventRetagSucceeded EventType = "retag_succeeded"
+	// EventRetagFailed is sent when promoting the target tag failed.
+	EventRetagFailed EventType = "retag_failed"
+	// EventRetagPlanned is sent in dry-run mode instead of retagging.
+	EventRetagPlanned EventType = "retag_planned"
+	// EventApprovalRequired is sent once when a reached upgrade waits for a manual approval.
+	EventApprovalRequired EventType = "approval_required"
+)
+
+// EventTypes lists all event types.
+var EventTypes = []EventType{
+	EventProposalPassed,
+	EventUpgradeIn1h,
+	EventUpgradeIn10m,
+	EventRetagSucceeded,
+	EventRetagFailed,
+	EventRetagPlanned,
+	EventApprovalRequired,
+}
+
+// Event describes something that happened to an upgrade plan. It is the data
+// message templates are rendered with.
+type Event struct {
+	// Target names the chain and environment the plan belongs to, e.g. "mainnet".
+	Target          string        `json:"target,omitempty"`
+	Type            EventType     `json:"type"`
+	Plan            string        `json:"plan"`
+	Height          int64         `json:"height"`
+	BlocksRemaining int64         `json:"blocks_remaining,omitempty"`
+	ETA             time.Duration `json:"eta,omitempty"`
+	// AutoApproveIn is how long until an upgrade awaiting approval is approved automatically, if ever.
+	AutoApproveIn time.Duration `json:"auto_approve_in,omitempty"`
+	Images        []Image       `json:"images"`
+	Error         string        `json:"error,omitempty"`
+	Time          time.Time     `json:"time"`
+}
+
+// Image is what the plan promotes in one repository.
+type Image struct {
+	Repo      string `json:"repo"`
+	SourceTag string `json:"source_tag"`
+	TargetTag string `json:"target_tag"`
+	Digest    string `json:"digest,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sink delivers rendered messages to a single destination.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	Send(ctx context.Context, event Event, message string) error
+}
+
+// NotifierInterface is what the updater uses to announce events.
+type NotifierInterface interface {
+	Notify(ctx context.Context, event Event)
+}
+
+// Notifier renders events with their templates and delivers them to every sink,
+// retrying failed deliveries with exponential backoff.
+type Notifier struct {
+	sinks     []Sink
+	templates map[EventType]*template.Template
+	wg        sync.WaitGroup
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+var _ NotifierInterface = (*Notifier)(nil)
+
+// NewNotifier creates a new notifier. templates overrides the default message template
+// of the event types it contains.
+func NewNotifier(sinks []Sink, templates map[EventType]string) (*Notifier, error) {
+	parsed := make(map[EventType]*template.Template, len(EventTypes))
+	for _, eventType := range EventTypes {
+		text, ok := templates[eventType]
+		if !ok {
+			text = defaultTemplates[eventType]
+		}
+		tmpl, err := template.New(string(eventType)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s template: %w", eventType, err)
+		}
+		parsed[eventType] = tmpl
+	}
+
+	return &Notifier{
+		sinks:       sinks,
+		templates:   parsed,
+		closed:      make(chan struct{}),
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+	}, nil
+}
+
+// Notify renders event and delivers it to every sink in the background, so a slow
+// or unreachable sink never delays an upgrade. Use Wait to flush pending deliveries.
+func (n *Notifier) Notify(ctx context.Context, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	message, err := n.render(event)
+	if err != nil {
+		xlog.Error("failed to render notification", "event", event.Type, "plan", event.Plan, "err", err)
+		return
+	}
+
+	// Deliveries outlive the check cycle that triggered them, until Close.
+	ctx = context.WithoutCancel(ctx)
+	for _, sink := range n.sinks {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			err := n.deliver(ctx, sink, event, message)
+			metrics.Notifications.WithLabelValues(sink.Name(), metrics.Result(err)).Inc()
+			if err != nil {
+				xlog.Error("failed to deliver notification", "sink", sink.Name(), "event", event.Type, "plan", event.Plan, "err", err)
+			}
+		}()
+	}
+}
+
+// Wait blocks until all pending deliveries have finished.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// Close makes pending deliveries give up instead of waiting to retry, and waits for
+// them to finish.
+func (n *Notifier) Close() {
+	n.closeOnce.Do(func() { close(n.closed) })
+	n.wg.Wait()
+}
+
+func (n *Notifier) render(event Event) (string, error) {
+	tmpl, ok := n.templates[event.Type]
+	if !ok {
+		return "", fmt.Errorf("unknown event type %q", event.Type)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// deliver sends the message to sink, retrying up to MaxAttempts times. It gives up
+// without waiting to retry once ctx is done or the Notifier is closed.
+func (n *Notifier) deliver(ctx context.Context, sink Sink, event Event, message string) error {
+	backoff := n.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = sink.Send(ctx, event, message); err == nil {
+			return nil
+		}
+		if attempt >= n.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		xlog.Warn("failed to deliver notification, retrying", "sink", sink.Name(), "event", event.Type, "attempt", attempt, "retry_in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		case <-n.closed:
+			return fmt.Errorf("giving up after %d attempts, shutting down: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
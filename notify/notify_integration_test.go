@@ -0,0 +1,230 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/notify"
+)
+
+var _ = Describe("Notifier", func() {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests []recordedRequest
+		failures int
+		event    notify.Event
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		failures = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, recordedRequest{Path: r.URL.Path, ContentType: r.Header.Get("Content-Type"), Body: body})
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+
+		event = notify.Event{
+			Type:   notify.EventRetagSucceeded,
+			Plan:   "v1.2.3",
+			Height: 100,
+			Images: []notify.Image{{
+				Repo:      "my/repo",
+				SourceTag: "release-v1.2.3",
+				TargetTag: "mainnet-v1.2.3",
+				Digest:    "sha256:abc",
+			}},
+		}
+	})
+
+	received := func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+
+	newNotifier := func(sink notify.Sink, templates map[notify.EventType]string) *notify.Notifier {
+		notifier, err := notify.NewNotifier([]notify.Sink{sink}, templates)
+		Expect(err).ToNot(HaveOccurred())
+		notifier.Backoff = time.Millisecond
+		return notifier
+	}
+
+	It("should post the event and message to a generic webhook", func() {
+		notifier := newNotifier(notify.NewWebhookSink(server.URL+"/hook", server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(1))
+		Expect(received()[0].Path).To(Equal("/hook"))
+		Expect(received()[0].ContentType).To(Equal("application/json"))
+
+		var payload map[string]any
+		Expect(json.Unmarshal(received()[0].Body, &payload)).To(Succeed())
+		Expect(payload["type"]).To(Equal("retag_succeeded"))
+		Expect(payload["plan"]).To(Equal("v1.2.3"))
+		Expect(payload["images"]).To(ConsistOf(HaveKeyWithValue("digest", "sha256:abc")))
+		Expect(payload["message"]).To(Equal("Promoted my/repo:release-v1.2.3 to mainnet-v1.2.3 (sha256:abc) for upgrade v1.2.3 at height 100."))
+	})
+
+	It("should post the message as text to Slack", func() {
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(1))
+		Expect(decode(received()[0].Body)).To(HaveKeyWithValue("text", ContainSubstring("Promoted my/repo:release-v1.2.3")))
+	})
+
+	It("should post the message as content to Discord", func() {
+		notifier := newNotifier(notify.NewDiscordSink(server.URL, server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(1))
+		Expect(decode(received()[0].Body)).To(HaveKeyWithValue("content", ContainSubstring("Promoted my/repo:release-v1.2.3")))
+	})
+
+	It("should send the message to a Telegram chat", func() {
+		sink := notify.NewTelegramSink("123:token", "-1001", server.Client())
+		sink.BaseURL = server.URL
+		notifier := newNotifier(sink, nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(1))
+		Expect(received()[0].Path).To(Equal("/bot123:token/sendMessage"))
+		body := decode(received()[0].Body)
+		Expect(body).To(HaveKeyWithValue("chat_id", "-1001"))
+		Expect(body).To(HaveKeyWithValue("text", ContainSubstring("Promoted my/repo:release-v1.2.3")))
+	})
+
+	It("should keep the bot token out of delivery errors", func() {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		sink := notify.NewTelegramSink("123:secret-token", "-1001", closed.Client())
+		sink.BaseURL = closed.URL
+
+		err := sink.Send(context.Background(), event, "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(closed.Listener.Addr().String()))
+		Expect(err.Error()).NotTo(ContainSubstring("secret-token"))
+	})
+
+	It("should render custom templates", func() {
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), map[notify.EventType]string{
+			notify.EventRetagSucceeded: "{{.Plan}} is live as {{(index .Images 0).TargetTag}}",
+		})
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(decode(received()[0].Body)).To(HaveKeyWithValue("text", "v1.2.3 is live as mainnet-v1.2.3"))
+	})
+
+	It("should list every image in the default templates", func() {
+		event.Images = append(event.Images, notify.Image{
+			Repo:      "my/price-feeder",
+			SourceTag: "release-v1.2.3",
+			TargetTag: "mainnet-v1.2.3",
+			Digest:    "sha256:def",
+		})
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(decode(received()[0].Body)).To(HaveKeyWithValue("text",
+			"Promoted my/repo:release-v1.2.3 to mainnet-v1.2.3 (sha256:abc), my/price-feeder:release-v1.2.3 to mainnet-v1.2.3 (sha256:def) for upgrade v1.2.3 at height 100."))
+	})
+
+	It("should prefix the default templates with the target", func() {
+		event.Target = "testnet"
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(decode(received()[0].Body)).To(HaveKeyWithValue("text",
+			"[testnet] Promoted my/repo:release-v1.2.3 to mainnet-v1.2.3 (sha256:abc) for upgrade v1.2.3 at height 100."))
+	})
+
+	It("should reject invalid templates", func() {
+		_, err := notify.NewNotifier(nil, map[notify.EventType]string{notify.EventRetagFailed: "{{.Plan"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should retry failed deliveries", func() {
+		failures = 2
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(3))
+	})
+
+	It("should give up after the maximum number of attempts", func() {
+		failures = 10
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.MaxAttempts = 2
+		notifier.Notify(context.Background(), event)
+		notifier.Wait()
+
+		Expect(received()).To(HaveLen(2))
+	})
+
+	It("should stop retrying once closed", func() {
+		failures = 10
+		notifier := newNotifier(notify.NewSlackSink(server.URL, server.Client()), nil)
+		notifier.Backoff = time.Hour
+		notifier.Notify(context.Background(), event)
+		Eventually(received).Should(HaveLen(1))
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			notifier.Close()
+		}()
+		Eventually(closed).Should(BeClosed())
+		Expect(received()).To(HaveLen(1))
+	})
+
+	It("should load templates from a directory", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "retag_failed.tmpl"), []byte("boom: {{.Error}}"), 0o600)).To(Succeed())
+
+		templates, err := notify.LoadTemplates(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(templates).To(Equal(map[notify.EventType]string{notify.EventRetagFailed: "boom: {{.Error}}"}))
+	})
+})
+
+type recordedRequest struct {
+	Path        string
+	ContentType string
+	Body        []byte
+}
+
+func decode(body []byte) map[string]any {
+	var out map[string]any
+	Expect(json.Unmarshal(body, &out)).To(Succeed())
+	return out
+}
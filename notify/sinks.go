@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebhookSink posts the event and the rendered message as JSON to a generic webhook.
+type WebhookSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookSink creates a new generic JSON webhook sink.
+func NewWebhookSink(url string, httpClient *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, httpClient: httpClient}
+}
+
+type webhookPayload struct {
+	Event
+	Message string `json:"message"`
+}
+
+// Name returns the name of the sink.
+func (s *WebhookSink) Name() string { return "webhook" }
+
+// Send posts the event to the webhook.
+func (s *WebhookSink) Send(ctx context.Context, event Event, message string) error {
+	return postJSON(ctx, s.httpClient, s.url, webhookPayload{Event: event, Message: message})
+}
+
+// SlackSink posts messages to a Slack incoming webhook.
+type SlackSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewSlackSink creates a new Slack incoming webhook sink.
+func NewSlackSink(url string, httpClient *http.Client) *SlackSink {
+	return &SlackSink{url: url, httpClient: httpClient}
+}
+
+// Name returns the name of the sink.
+func (s *SlackSink) Name() string { return "slack" }
+
+// Send posts the message to Slack.
+func (s *SlackSink) Send(ctx context.Context, _ Event, message string) error {
+	return postJSON(ctx, s.httpClient, s.url, map[string]string{"text": message})
+}
+
+// DiscordSink posts messages to a Discord webhook.
+type DiscordSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewDiscordSink creates a new Discord webhook sink.
+func NewDiscordSink(url string, httpClient *http.Client) *DiscordSink {
+	return &DiscordSink{url: url, httpClient: httpClient}
+}
+
+// Name returns the name of the sink.
+func (s *DiscordSink) Name() string { return "discord" }
+
+// Send posts the message to Discord.
+func (s *DiscordSink) Send(ctx context.Context, _ Event, message string) error {
+	return postJSON(ctx, s.httpClient, s.url, map[string]string{"content": message})
+}
+
+// TelegramSink sends messages to a chat through a Telegram bot.
+type TelegramSink struct {
+	token      string
+	chatID     string
+	httpClient *http.Client
+
+	// BaseURL is the Telegram Bot API URL, overridable for tests.
+	BaseURL string
+}
+
+// NewTelegramSink creates a new Telegram bot sink that sends to chatID.
+func NewTelegramSink(token, chatID string, httpClient *http.Client) *TelegramSink {
+	return &TelegramSink{
+		token:      token,
+		chatID:     chatID,
+		httpClient: httpClient,
+		BaseURL:    "https://api.telegram.org",
+	}
+}
+
+// Name returns the name of the sink.
+func (s *TelegramSink) Name() string { return "telegram" }
+
+// Send sends the message to the Telegram chat.
+func (s *TelegramSink) Send(ctx context.Context, _ Event, message string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(s.BaseURL, "/"), s.token)
+	return postJSON(ctx, s.httpClient, endpoint, map[string]string{"chat_id": s.chatID, "text": message})
+}
+
+// postJSON posts payload as JSON to endpoint and fails on any non-2xx response.
+// Errors never include the path or query of endpoint, which carry the credentials
+// of webhooks and bots and would end up in the logs.
+func postJSON(ctx context.Context, httpClient *http.Client, endpoint string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("failed to create request: invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(req.URL)
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// redactURL returns u without its user info, path and query.
+func redactURL(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// images lists the images of an event, e.g. "my/repo:release-v2 to mainnet-v2".
+const images = `{{range $i, $image := .Images}}{{if $i}}, {{end}}{{$image.Repo}}:{{$image.SourceTag}} to {{$image.TargetTag}}` +
+	`{{if $image.Digest}} ({{$image.Digest}}){{end}}{{end}}`
+
+// target prefixes messages with the target of the event, e.g. "[mainnet] ".
+const target = `{{if .Target}}[{{.Target}}] {{end}}`
+
+var defaultTemplates = map[EventType]string{
+	EventProposalPassed: target + "Upgrade {{.Plan}} is scheduled at height {{.Height}}, {{.BlocksRemaining}} blocks from now. " +
+		"It will promote " + images + ".",
+	EventUpgradeIn1h:    target + "Upgrade {{.Plan}} is about {{.ETA}} away, at height {{.Height}} ({{.BlocksRemaining}} blocks).",
+	EventUpgradeIn10m:   target + "Upgrade {{.Plan}} is about {{.ETA}} away, at height {{.Height}} ({{.BlocksRemaining}} blocks).",
+	EventRetagSucceeded: target + "Promoted " + images + " for upgrade {{.Plan}} at height {{.Height}}.",
+	EventRetagPlanned:   target + "Dry run: would promote " + images + " for upgrade {{.Plan}} at height {{.Height}}.",
+	EventRetagFailed:    target + "Failed to promote upgrade {{.Plan}} at height {{.Height}}: {{.Error}}",
+	EventApprovalRequired: target + "Upgrade {{.Plan}} reached height {{.Height}} and awaits approval to promote " + images + "." +
+		"{{if .AutoApproveIn}} It will be approved automatically in {{.AutoApproveIn}}.{{end}}",
+}
+
+// LoadTemplates reads message templates from dir, one file per event type named
+// <event type>.tmpl, e.g. retag_failed.tmpl. Event types without a file keep their
+// default template. An empty dir loads nothing.
+func LoadTemplates(dir string) (map[EventType]string, error) {
+	templates := map[EventType]string{}
+	if dir == "" {
+		return templates, nil
+	}
+	for _, eventType := range EventTypes {
+		data, err := os.ReadFile(filepath.Join(dir, string(eventType)+".tmpl"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", eventType, err)
+		}
+		templates[eventType] = string(data)
+	}
+	return templates, nil
+}
@@ -0,0 +1,81 @@
+// Package imageref parses image references such as
+// registry.example.com/org/repo:tag@sha256:<hex>.
+package imageref
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gopher-lab/gopher-updater/pkg/tagtemplate"
+)
+
+// Reference is a parsed image reference. Any part may be empty.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// repositoryRegexp is the OCI distribution repository name grammar.
+var repositoryRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// Parse parses an image reference. Besides full references it accepts a bare tag
+// (v1.2.3), a bare digest (sha256:<hex> or @sha256:<hex>), repo:tag and repo@digest.
+// A first path component that looks like a host name (it contains a '.' or ':', or is
+// localhost) is returned as the registry. The repository is returned as written, so a
+// Docker Hub short name such as foo is not expanded to library/foo.
+func Parse(s string) (Reference, error) {
+	var ref Reference
+	if s == "" {
+		return ref, fmt.Errorf("empty image reference")
+	}
+
+	name := s
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !digestRegexp.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", ref.Digest, s)
+		}
+	} else if digestRegexp.MatchString(name) {
+		name, ref.Digest = "", name
+	}
+
+	// A tag follows the last ':' after the last '/'; a ':' before it belongs to a registry port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	} else if !strings.Contains(name, "/") {
+		// Without a '/' or ':', a lone name is a tag, since the repository is implied.
+		name, ref.Tag = "", name
+	}
+	if ref.Tag != "" {
+		if err := tagtemplate.ValidateTag(ref.Tag); err != nil {
+			return ref, fmt.Errorf("invalid image reference %q: %w", s, err)
+		}
+	}
+
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, name = first, rest
+	}
+	if name != "" && !repositoryRegexp.MatchString(name) {
+		return ref, fmt.Errorf("invalid repository %q in image reference %q", name, s)
+	}
+	ref.Repository = name
+
+	if ref.Tag == "" && ref.Digest == "" {
+		return ref, fmt.Errorf("image reference %q has neither a tag nor a digest", s)
+	}
+	return ref, nil
+}
+
+// Reference returns the digest if there is one, since it is immutable, or else the tag.
+// This is what manifest requests are made for.
+func (r Reference) Reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
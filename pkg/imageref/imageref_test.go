@@ -0,0 +1,77 @@
+package imageref_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/pkg/imageref"
+)
+
+var _ = Describe("Parse", func() {
+	digest := "sha256:" + strings.Repeat("a1", 32)
+
+	DescribeTable("should parse",
+		func(s string, expected imageref.Reference) {
+			Expect(imageref.Parse(s)).To(Equal(expected))
+		},
+		Entry("a registry with a port", "registry.example.com:5000/org/repo:v1.2.3",
+			imageref.Reference{Registry: "registry.example.com:5000", Repository: "org/repo", Tag: "v1.2.3"}),
+		Entry("localhost with a port", "localhost:5000/repo:v1",
+			imageref.Reference{Registry: "localhost:5000", Repository: "repo", Tag: "v1"}),
+		Entry("localhost", "localhost/repo:v1",
+			imageref.Reference{Registry: "localhost", Repository: "repo", Tag: "v1"}),
+		Entry("a repository with a digest", "org/repo@"+digest,
+			imageref.Reference{Repository: "org/repo", Digest: digest}),
+		Entry("a repository with a tag and a digest", "org/repo:v1@"+digest,
+			imageref.Reference{Repository: "org/repo", Tag: "v1", Digest: digest}),
+		Entry("a full reference", "ghcr.io/gopher-lab/gopher:v1@"+digest,
+			imageref.Reference{Registry: "ghcr.io", Repository: "gopher-lab/gopher", Tag: "v1", Digest: digest}),
+		Entry("a bare tag", "release-v1.2.3",
+			imageref.Reference{Tag: "release-v1.2.3"}),
+		Entry("a bare digest", digest,
+			imageref.Reference{Digest: digest}),
+		Entry("a bare digest after '@'", "@"+digest,
+			imageref.Reference{Digest: digest}),
+		Entry("a Docker Hub short name as written", "foo:v1",
+			imageref.Reference{Repository: "foo", Tag: "v1"}),
+		Entry("a Docker Hub official image", "library/foo:v1",
+			imageref.Reference{Repository: "library/foo", Tag: "v1"}),
+		Entry("a Docker Hub short name with the registry", "docker.io/foo:v1",
+			imageref.Reference{Registry: "docker.io", Repository: "foo", Tag: "v1"}),
+		Entry("a first component without a dot as part of the repository", "gopher-lab/gopher:v1",
+			imageref.Reference{Repository: "gopher-lab/gopher", Tag: "v1"}),
+		Entry("separators in the repository", "org/my_repo.name--x:v1",
+			imageref.Reference{Repository: "org/my_repo.name--x", Tag: "v1"}),
+	)
+
+	DescribeTable("should reject",
+		func(s, message string) {
+			_, err := imageref.Parse(s)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("an empty reference", "", "empty image reference"),
+		Entry("a repository without a tag or digest", "org/repo", "neither a tag nor a digest"),
+		Entry("a registry and repository without a tag or digest", "registry.example.com:5000/org/repo", "neither a tag nor a digest"),
+		Entry("an empty tag", "org/repo:", "neither a tag nor a digest"),
+		Entry("an invalid tag", "org/repo:-v1", "invalid tag"),
+		Entry("an empty digest", "org/repo:v1@", "invalid digest"),
+		Entry("a short digest", "org/repo@sha256:abc", "invalid digest"),
+		Entry("another digest algorithm", "org/repo@md5:"+strings.Repeat("a", 32), "invalid digest"),
+		Entry("an upper-case digest", "org/repo@sha256:"+strings.Repeat("A", 64), "invalid digest"),
+		Entry("an upper-case repository", "Org/Repo:v1", "invalid repository"),
+		Entry("an empty path component", "org//repo:v1", "invalid repository"),
+		Entry("a trailing slash", "org/repo/:v1", "invalid repository"),
+		Entry("a space in the repository", "org/my repo:v1", "invalid repository"),
+		Entry("a leading separator in a component", "org/-repo:v1", "invalid repository"),
+	)
+})
+
+var _ = Describe("Reference", func() {
+	It("should prefer the digest over the tag", func() {
+		digest := "sha256:" + strings.Repeat("b2", 32)
+		Expect(imageref.Reference{Tag: "v1", Digest: digest}.Reference()).To(Equal(digest))
+		Expect(imageref.Reference{Tag: "v1"}.Reference()).To(Equal("v1"))
+	})
+})
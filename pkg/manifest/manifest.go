@@ -0,0 +1,69 @@
+// Package manifest holds the image manifest media types and digest helpers shared
+// by the registry clients.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Manifest media types understood by the registry clients.
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// Accept is the Accept header value that negotiates every supported manifest type,
+// so the registry returns the manifest as stored instead of converting it.
+var Accept = strings.Join([]string{
+	MediaTypeOCIIndex,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeDockerManifest,
+}, ", ")
+
+// DigestHeader is the response header registries use to report a manifest digest.
+const DigestHeader = "Docker-Content-Digest"
+
+// Digest returns the sha256 digest of the exact manifest bytes.
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// MediaType returns the media type to PUT body with. It prefers the Content-Type the
+// registry returned and falls back to the mediaType field of the manifest itself.
+func MediaType(body []byte, contentType string) (string, error) {
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && mediaType != "application/json" && mediaType != "application/octet-stream" {
+			return contentType, nil
+		}
+	}
+
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if m.MediaType == "" {
+		return "", fmt.Errorf("manifest does not declare a media type")
+	}
+	return m.MediaType, nil
+}
+
+// VerifyDigest checks that the digest a registry reported matches the expected one.
+// An empty reported digest is accepted, since registries are not required to send it.
+func VerifyDigest(expected, reported string) error {
+	if reported != "" && reported != expected {
+		return fmt.Errorf("digest mismatch: expected %s, registry reported %s", expected, reported)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package tagtemplate renders image tags from upgrade plans with text/template.
+package tagtemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Data is what tag templates are rendered with.
+type Data struct {
+	// Name is the upgrade plan name, e.g. v2.
+	Name string
+	// Height is the upgrade height.
+	Height int64
+	// chainID resolves the chain ID, see ChainID.
+	chainID func() (string, error)
+
+	// Semver reports whether Name parsed as a semantic version. Major, Minor, Patch,
+	// Prerelease and Metadata are only set if it did; missing parts are zero, so v2
+	// has Major 2, Minor 0 and Patch 0.
+	Semver     bool
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Metadata   string
+}
+
+// NewData returns the template data for a plan. chainID is only called if a template
+// uses the chain ID.
+func NewData(name string, height int64, chainID func() (string, error)) Data {
+	data := Data{Name: name, Height: height, chainID: chainID}
+	if v, err := semver.NewVersion(name); err == nil {
+		data.Semver = true
+		data.Major = v.Major()
+		data.Minor = v.Minor()
+		data.Patch = v.Patch()
+		data.Prerelease = v.Prerelease()
+		data.Metadata = v.Metadata()
+	}
+	return data
+}
+
+// ChainID returns the chain ID reported by the node. A template using it fails to
+// render if it cannot be resolved, rather than rendering a tag without it.
+func (d Data) ChainID() (string, error) {
+	if d.chainID == nil {
+		return "", errors.New("chain id is unknown")
+	}
+	return d.chainID()
+}
+
+// tagRegexp is the OCI distribution tag grammar.
+var tagRegexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// invalidTagChars matches the characters that are not allowed anywhere in a tag.
+var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// ValidateTag returns an error if tag is not a valid OCI tag.
+func ValidateTag(tag string) error {
+	if !tagRegexp.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: must match %s", tag, tagRegexp)
+	}
+	return nil
+}
+
+// Sanitize turns s into a valid tag by replacing invalid characters with '-',
+// dropping leading '.' and '-' and truncating it to 128 characters.
+func Sanitize(s string) string {
+	s = invalidTagChars.ReplaceAllString(s, "-")
+	s = strings.TrimLeft(s, ".-")
+	if len(s) > 128 {
+		s = s[:128]
+	}
+	return s
+}
+
+var funcs = template.FuncMap{
+	"sanitize": Sanitize,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	// replace is meant for pipelines: {{.Name | replace "^v" ""}}.
+	"replace": func(pattern, replacement, s string) (string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(s, replacement), nil
+	},
+}
+
+// Template renders tags from plan data.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses a tag template. Besides the Data fields, templates can use the
+// sanitize, lower, upper and replace functions.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("tag").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tag template: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// PrefixTemplate returns the template text that renders prefix followed by the plan
+// name, which is how tags are named when no template is configured.
+func PrefixTemplate(prefix string) string {
+	if prefix == "" {
+		return "{{.Name}}"
+	}
+	return "{{" + strconv.Quote(prefix) + "}}{{.Name}}"
+}
+
+// Render renders the tag for data and validates it.
+func (t *Template) Render(data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render tag template: %w", err)
+	}
+	tag := buf.String()
+	if err := ValidateTag(tag); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
@@ -0,0 +1,13 @@
+package tagtemplate_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTagTemplate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TagTemplate Suite")
+}
@@ -0,0 +1,128 @@
+package tagtemplate_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/pkg/tagtemplate"
+)
+
+var _ = Describe("TagTemplate", func() {
+	chainID := func() (string, error) { return "gopher-1", nil }
+
+	DescribeTable("Sanitize",
+		func(s, expected string) {
+			Expect(tagtemplate.Sanitize(s)).To(Equal(expected))
+		},
+		Entry("keeps a valid tag", "mainnet-v1.2.3", "mainnet-v1.2.3"),
+		Entry("replaces runs of invalid characters", "v1.2.3+build/meta data", "v1.2.3-build-meta-data"),
+		Entry("drops leading dots and dashes", "..-v1", "v1"),
+		Entry("drops a leading dash left by a replacement", "/v1", "v1"),
+		Entry("keeps a leading underscore", "_v1", "_v1"),
+		Entry("truncates to 128 characters", strings.Repeat("a", 200), strings.Repeat("a", 128)),
+	)
+
+	DescribeTable("ValidateTag",
+		func(tag string, valid bool) {
+			if valid {
+				Expect(tagtemplate.ValidateTag(tag)).To(Succeed())
+			} else {
+				Expect(tagtemplate.ValidateTag(tag)).To(MatchError(ContainSubstring("invalid tag")))
+			}
+		},
+		Entry("a plain tag", "mainnet-v1.2.3", true),
+		Entry("128 characters", strings.Repeat("a", 128), true),
+		Entry("an empty tag", "", false),
+		Entry("129 characters", strings.Repeat("a", 129), false),
+		Entry("a leading dot", ".v1", false),
+		Entry("a leading dash", "-v1", false),
+		Entry("a slash", "v1/2", false),
+		Entry("a plus sign", "v1+meta", false),
+	)
+
+	DescribeTable("Render",
+		func(text, name, expected string) {
+			tmpl, err := tagtemplate.Parse(text)
+			Expect(err).NotTo(HaveOccurred())
+			tag, err := tmpl.Render(tagtemplate.NewData(name, 100, chainID))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tag).To(Equal(expected))
+		},
+		Entry("the plan name", "{{.Name}}", "v2.1.0", "v2.1.0"),
+		Entry("the height", "{{.Name}}-{{.Height}}", "v2", "v2-100"),
+		Entry("semver parts", "{{.Major}}.{{.Minor}}.{{.Patch}}", "v2.1.3-rc.1", "2.1.3"),
+		Entry("missing semver parts as zero", "{{.Major}}.{{.Minor}}.{{.Patch}}", "v2", "2.0.0"),
+		Entry("the prerelease", "{{.Prerelease}}", "v2.1.3-rc.1", "rc.1"),
+		Entry("the chain id", "{{.ChainID}}-{{.Name}}", "v2", "gopher-1-v2"),
+		Entry("replace in a pipeline", `{{.Name | replace "^v" ""}}`, "v2.1.0", "2.1.0"),
+		Entry("replace with a capture group", `{{.Name | replace "^v(\\d+).*$" "major-$1"}}`, "v2.1.0", "major-2"),
+		Entry("lower", "{{lower .Name}}", "V2-RC", "v2-rc"),
+		Entry("upper", "{{upper .Name}}", "v2-rc", "V2-RC"),
+		Entry("sanitize", "{{sanitize .Name}}", "v2+build/1", "v2-build-1"),
+	)
+
+	It("should report whether the name is a semantic version", func() {
+		Expect(tagtemplate.NewData("v2.1.0", 100, nil).Semver).To(BeTrue())
+		Expect(tagtemplate.NewData("gopher-upgrade", 100, nil).Semver).To(BeFalse())
+	})
+
+	DescribeTable("PrefixTemplate",
+		func(prefix, name, expected string) {
+			tmpl, err := tagtemplate.Parse(tagtemplate.PrefixTemplate(prefix))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpl.Render(tagtemplate.NewData(name, 100, nil))).To(Equal(expected))
+		},
+		Entry("no prefix", "", "v2", "v2"),
+		Entry("a prefix", "mainnet-", "v2", "mainnet-v2"),
+		Entry("a prefix with a dot", "gopher.", "v2", "gopher.v2"),
+	)
+
+	DescribeTable("PrefixTemplate should keep the prefix literal",
+		func(prefix string) {
+			tmpl, err := tagtemplate.Parse(tagtemplate.PrefixTemplate(prefix))
+			Expect(err).NotTo(HaveOccurred())
+			// The prefix is not a valid tag, which shows how it was rendered.
+			_, err = tmpl.Render(tagtemplate.NewData("v2", 100, nil))
+			Expect(err).To(MatchError(ContainSubstring(strconv.Quote(prefix + "v2"))))
+		},
+		Entry("template delimiters", "{{.Height}}"),
+		Entry("quotes and backslashes", `a"b\`),
+	)
+
+	DescribeTable("should fail to render",
+		func(text string, chainID func() (string, error), message string) {
+			tmpl, err := tagtemplate.Parse(text)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = tmpl.Render(tagtemplate.NewData("v2", 100, chainID))
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("an unknown field", "{{.Version}}", nil, "Version"),
+		Entry("a missing map key", `{{index .Missing "key"}}`, nil, "Missing"),
+		Entry("an unresolved chain id", "{{.ChainID}}", nil, "chain id is unknown"),
+		Entry("a chain id that fails to resolve", "{{.ChainID}}", func() (string, error) { return "", errors.New("node down") }, "node down"),
+		Entry("an invalid replace pattern", `{{.Name | replace "(" ""}}`, nil, "error parsing regexp"),
+		Entry("an invalid rendered tag", "{{.Name}}/latest", nil, "invalid tag"),
+		Entry("an empty rendered tag", "{{.Prerelease}}", nil, "invalid tag"),
+	)
+
+	It("should fail to parse an invalid template", func() {
+		_, err := tagtemplate.Parse("{{.Name")
+		Expect(err).To(MatchError(ContainSubstring("failed to parse tag template")))
+	})
+
+	It("should not resolve the chain id unless the template uses it", func() {
+		tmpl, err := tagtemplate.Parse("{{.Name}}")
+		Expect(err).NotTo(HaveOccurred())
+		resolved := false
+		_, err = tmpl.Render(tagtemplate.NewData("v2", 100, func() (string, error) {
+			resolved = true
+			return "gopher-1", nil
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved).To(BeFalse())
+	})
+})
@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// challenge is a parsed WWW-Authenticate header.
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull"`.
+// Quoted values may contain commas.
+func parseChallenge(header string) (challenge, error) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if scheme == "" {
+		return challenge{}, fmt.Errorf("empty authentication challenge")
+	}
+
+	params := map[string]string{}
+	rest = strings.TrimSpace(rest)
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return challenge{}, fmt.Errorf("malformed authentication challenge: %q", header)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return challenge{}, fmt.Errorf("unterminated quoted value in authentication challenge: %q", header)
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			v, r, _ := strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(v)
+			rest = "," + r
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+
+	return challenge{scheme: strings.ToLower(scheme), params: params}, nil
+}
+
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// authorize answers an authentication challenge and returns the value of the
+// Authorization header to retry the request with.
+func (c *Client) authorize(ctx context.Context, header, scope string) (string, error) {
+	ch, err := parseChallenge(header)
+	if err != nil {
+		return "", err
+	}
+
+	switch ch.scheme {
+	case "basic":
+		if c.user == "" {
+			return "", fmt.Errorf("registry requires basic authentication but no credentials are configured")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.user+":"+c.password)), nil
+	case "bearer":
+		token, err := c.getBearerToken(ctx, ch, scope)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication scheme %q", ch.scheme)
+	}
+}
+
+// getBearerToken fetches a token from the realm of a Bearer challenge. Credentials are
+// sent if configured; otherwise an anonymous token is requested.
+func (c *Client) getBearerToken(ctx context.Context, ch challenge, scope string) (string, error) {
+	realm := ch.params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without realm")
+	}
+	authURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid bearer realm %q: %w", realm, err)
+	}
+
+	query := authURL.Query()
+	if service := ch.params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope == "" {
+		scope = ch.params["scope"]
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	authURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create auth request: %w", err)
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("auth request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth failed with status: %s", resp.Status)
+	}
+
+	var tokenResp tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode auth response: %w", err)
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	if tokenResp.AccessToken != "" {
+		return tokenResp.AccessToken, nil
+	}
+	return "", fmt.Errorf("auth response did not contain a token")
+}
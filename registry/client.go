@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gopher-lab/gopher-updater/dockerhub"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/manifest"
+)
+
+// Client for interacting with any registry that implements the OCI Distribution API,
+// such as GHCR, Harbor or a self-hosted registry:2. Authentication is discovered from
+// the WWW-Authenticate challenge, so Bearer, Basic and anonymous access all work.
+type Client struct {
+	baseURL    string
+	user       string
+	password   string
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// authByScope caches the Authorization header obtained for each scope.
+	authByScope map[string]string
+}
+
+// NewClient creates a new registry client. user and password may be empty for anonymous access.
+func NewClient(baseURL, user, password string, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		user:        user,
+		password:    password,
+		httpClient:  metrics.InstrumentClient("registry", httpClient),
+		authByScope: map[string]string{},
+	}
+}
+
+var _ dockerhub.ClientInterface = (*Client)(nil)
+
+// TagExists checks if a specific tag exists for a repository.
+func (c *Client) TagExists(ctx context.Context, repoPath, tag string) (bool, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repoPath)
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repoPath, tag)
+
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create manifest head request: %w", err)
+		}
+		req.Header.Set("Accept", manifest.Accept)
+		return req, nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusOK {
+		return true, nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("unexpected status code when checking tag: %s", resp.Status)
+}
+
+// ManifestDigest returns the digest of the manifest a tag points to.
+func (c *Client) ManifestDigest(ctx context.Context, repoPath, tag string) (string, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repoPath)
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repoPath, tag)
+
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create manifest get request: %w", err)
+		}
+		req.Header.Set("Accept", manifest.Accept)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to get manifest, status: %s, body: %s", resp.Status, string(body))
+	}
+
+	manifestBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest body: %w", err)
+	}
+	digest := manifest.Digest(manifestBody)
+	if err := manifest.VerifyDigest(digest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return "", fmt.Errorf("manifest %s: %w", tag, err)
+	}
+	return digest, nil
+}
+
+// RetagImage retags an image from a source tag to a target tag by copying its manifest.
+func (c *Client) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+	scope := fmt.Sprintf("repository:%s:pull,push", repoPath)
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repoPath, sourceTag)
+	resp, err := c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create manifest get request: %w", err)
+		}
+		req.Header.Set("Accept", manifest.Accept)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to get manifest, status: %s, body: %s", resp.Status, string(body))
+	}
+
+	manifestBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest body: %w", err)
+	}
+	// The digest is computed over the exact bytes, which we PUT back unchanged so the
+	// target tag points at the very same manifest.
+	sourceDigest := manifest.Digest(manifestBody)
+	if err := manifest.VerifyDigest(sourceDigest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return fmt.Errorf("source manifest %s: %w", sourceTag, err)
+	}
+	contentType, err := manifest.MediaType(manifestBody, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("failed to determine manifest media type: %w", err)
+	}
+
+	// Now PUT the manifest with the new tag
+	targetURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repoPath, targetTag)
+	resp, err = c.do(ctx, scope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, targetURL, bytes.NewReader(manifestBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create manifest put request: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put manifest: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to put manifest, status: %s, body: %s", resp.Status, string(body))
+	}
+	if err := manifest.VerifyDigest(sourceDigest, resp.Header.Get(manifest.DigestHeader)); err != nil {
+		return fmt.Errorf("target manifest %s: %w", targetTag, err)
+	}
+
+	return nil
+}
+
+// do sends the request built by newRequest, using any cached authorization for scope.
+// If the registry answers with 401, the challenge is answered and the request is
+// rebuilt and retried once.
+func (c *Client) do(ctx context.Context, scope string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if auth := c.cachedAuth(scope); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	header := resp.Header.Get("WWW-Authenticate")
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if header == "" {
+		return nil, fmt.Errorf("registry returned %s without an authentication challenge", resp.Status)
+	}
+
+	auth, err := c.authorize(ctx, header, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	c.setAuth(scope, auth)
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) cachedAuth(scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.authByScope[scope]
+}
+
+func (c *Client) setAuth(scope, auth string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authByScope[scope] = auth
+}
@@ -0,0 +1,267 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/registry"
+)
+
+type authMode int
+
+const (
+	authAnonymous authMode = iota
+	authBasic
+	authBearer
+)
+
+type storedManifest struct {
+	body        []byte
+	contentType string
+}
+
+// fakeRegistry is a minimal in-process OCI Distribution registry.
+type fakeRegistry struct {
+	*httptest.Server
+	mode authMode
+
+	mu         sync.Mutex
+	manifests  map[string]storedManifest
+	tokenCalls int
+}
+
+func newFakeRegistry(mode authMode) *fakeRegistry {
+	r := &fakeRegistry{mode: mode, manifests: map[string]storedManifest{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+func (r *fakeRegistry) put(ref string, body, contentType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifests[ref] = storedManifest{body: []byte(body), contentType: contentType}
+}
+
+func (r *fakeRegistry) get(ref string) (storedManifest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.manifests[ref]
+	return m, ok
+}
+
+func (r *fakeRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		r.serveToken(w, req)
+		return
+	}
+	if !r.authorized(req) {
+		switch r.mode {
+		case authBasic:
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		case authBearer:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake-registry",scope="repository:ignored:pull"`, r.URL))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	repoAndRef, ok := strings.CutPrefix(req.URL.Path, "/v2/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	repo, tag, ok := strings.Cut(repoAndRef, "/manifests/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	ref := repo + ":" + tag
+
+	switch req.Method {
+	case http.MethodHead, http.MethodGet:
+		m, ok := r.get(ref)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.contentType)
+		w.Header().Set("Docker-Content-Digest", digestOf(m.body))
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(m.body)
+		}
+	case http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+		r.put(ref, string(body), req.Header.Get("Content-Type"))
+		w.Header().Set("Docker-Content-Digest", digestOf(body))
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func digestOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *fakeRegistry) authorized(req *http.Request) bool {
+	switch r.mode {
+	case authBasic:
+		user, pass, ok := req.BasicAuth()
+		return ok && user == "user" && pass == "pass"
+	case authBearer:
+		return strings.HasPrefix(req.Header.Get("Authorization"), "Bearer token-for-")
+	default:
+		return true
+	}
+}
+
+func (r *fakeRegistry) serveToken(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.tokenCalls++
+	r.mu.Unlock()
+
+	Expect(req.URL.Query().Get("service")).To(Equal("fake-registry"))
+	scope := req.URL.Query().Get("scope")
+	// Anonymous users may only pull.
+	if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		if strings.HasSuffix(scope, "push") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	_, err := fmt.Fprintf(w, `{"access_token":"token-for-%s"}`, scope)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Client Integration", func() {
+	const (
+		manifestContent     = `{"schemaVersion":2}`
+		manifestContentType = "application/vnd.docker.distribution.manifest.v2+json"
+	)
+
+	var (
+		reg *fakeRegistry
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		reg.Close()
+	})
+
+	for _, tc := range []struct {
+		name string
+		mode authMode
+	}{
+		{"anonymous", authAnonymous},
+		{"basic", authBasic},
+		{"bearer", authBearer},
+	} {
+		Context("with "+tc.name+" authentication", func() {
+			var client *registry.Client
+
+			BeforeEach(func() {
+				reg = newFakeRegistry(tc.mode)
+				client = registry.NewClient(reg.URL, "user", "pass", reg.Client())
+				reg.put("my/repo:source-tag", manifestContent, manifestContentType)
+			})
+
+			It("should report whether a tag exists", func() {
+				exists, err := client.TagExists(ctx, "my/repo", "source-tag")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exists).To(BeTrue())
+
+				exists, err = client.TagExists(ctx, "my/repo", "nonexistent")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exists).To(BeFalse())
+			})
+
+			It("should retag an image by copying its manifest", func() {
+				err := client.RetagImage(ctx, "my/repo", "source-tag", "target-tag")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, ok := reg.get("my/repo:target-tag")
+				Expect(ok).To(BeTrue())
+				Expect(string(m.body)).To(Equal(manifestContent))
+				Expect(m.contentType).To(Equal(manifestContentType))
+			})
+
+			It("should preserve an OCI image index and its digest", func() {
+				const indexContent = "{\"schemaVersion\":2,\n\"mediaType\":\"application/vnd.oci.image.index.v1+json\",\"manifests\":[]}"
+				reg.put("my/repo:oci-source", indexContent, "application/vnd.oci.image.index.v1+json")
+
+				err := client.RetagImage(ctx, "my/repo", "oci-source", "oci-target")
+				Expect(err).NotTo(HaveOccurred())
+
+				source, _ := reg.get("my/repo:oci-source")
+				target, ok := reg.get("my/repo:oci-target")
+				Expect(ok).To(BeTrue())
+				Expect(target.contentType).To(Equal("application/vnd.oci.image.index.v1+json"))
+				Expect(digestOf(target.body)).To(Equal(digestOf(source.body)))
+			})
+
+			It("should resolve the digest of a tag", func() {
+				digest, err := client.ManifestDigest(ctx, "my/repo", "source-tag")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(digest).To(Equal(digestOf([]byte(manifestContent))))
+			})
+
+			It("should return an error if the source tag does not exist", func() {
+				err := client.RetagImage(ctx, "my/repo", "missing-tag", "target-tag")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	}
+
+	Context("with bearer authentication", func() {
+		It("should reuse the token for the same scope", func() {
+			reg = newFakeRegistry(authBearer)
+			client := registry.NewClient(reg.URL, "user", "pass", reg.Client())
+
+			for range 3 {
+				_, err := client.TagExists(ctx, "my/repo", "latest")
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(reg.tokenCalls).To(Equal(1))
+		})
+
+		It("should pull anonymously without credentials", func() {
+			reg = newFakeRegistry(authBearer)
+			reg.put("my/repo:source-tag", manifestContent, manifestContentType)
+			client := registry.NewClient(reg.URL, "", "", reg.Client())
+
+			exists, err := client.TagExists(ctx, "my/repo", "source-tag")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			err = client.RetagImage(ctx, "my/repo", "source-tag", "target-tag")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with basic authentication", func() {
+		It("should fail without credentials", func() {
+			reg = newFakeRegistry(authBasic)
+			client := registry.NewClient(reg.URL, "", "", reg.Client())
+
+			_, err := client.TagExists(ctx, "my/repo", "latest")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,192 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
+)
+
+// ConfigMapStore keeps records in a Kubernetes ConfigMap, one JSON-encoded record
+// per data key, and the runtime state under runtimeKey. Updates use the ConfigMap's
+// resourceVersion and are retried on conflict.
+type ConfigMapStore struct {
+	mu        sync.Mutex
+	client    *kube.Client
+	namespace string
+	name      string
+}
+
+// NewConfigMapStore creates a new ConfigMap-backed store. The ConfigMap is created on first write.
+func NewConfigMapStore(client *kube.Client, namespace, name string) *ConfigMapStore {
+	return &ConfigMapStore{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+var _ Store = (*ConfigMapStore)(nil)
+
+const maxConflictRetries = 5
+
+type configMap struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   kube.ObjectMeta   `json:"metadata"`
+	Data       map[string]string `json:"data"`
+}
+
+// escapedKeyChars matches the characters of a plan name that configMapKey escapes:
+// those not allowed in ConfigMap data keys, and the escape character itself.
+var escapedKeyChars = regexp.MustCompile(`[^-.a-zA-Z0-9]`)
+
+// configMapKey returns the data key of plan. Escaped characters are replaced by an
+// underscore followed by the hex encoding of their bytes, so distinct plan names, e.g.
+// v1/2 and v1_2, never share a key.
+func configMapKey(plan string) string {
+	return escapedKeyChars.ReplaceAllStringFunc(plan, func(s string) string {
+		var b strings.Builder
+		for _, c := range []byte(s) {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+		return b.String()
+	})
+}
+
+// runtimeKey is the data key of the runtime state. configMapKey only produces an
+// underscore followed by two hex digits, so it never returns runtimeKey.
+const runtimeKey = "_runtime"
+
+// Get returns the record for the named plan, or nil if it was never promoted.
+func (s *ConfigMapStore) Get(ctx context.Context, plan string) (*Record, error) {
+	cm, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[configMapKey(plan)]
+	if !ok {
+		return nil, nil
+	}
+
+	var record Record
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("failed to decode record for plan %s: %w", plan, err)
+	}
+	return &record, nil
+}
+
+// Put stores a record, replacing any previous record for the same plan.
+func (s *ConfigMapStore) Put(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	return s.update(ctx, configMapKey(record.Plan), data)
+}
+
+// GetRuntime returns the runtime state, or nil if it was never stored.
+func (s *ConfigMapStore) GetRuntime(ctx context.Context) (*Runtime, error) {
+	cm, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[runtimeKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var runtime Runtime
+	if err := json.Unmarshal([]byte(data), &runtime); err != nil {
+		return nil, fmt.Errorf("failed to decode runtime state: %w", err)
+	}
+	return &runtime, nil
+}
+
+// PutRuntime stores the runtime state, replacing the previous one.
+func (s *ConfigMapStore) PutRuntime(ctx context.Context, runtime Runtime) error {
+	data, err := json.Marshal(runtime)
+	if err != nil {
+		return fmt.Errorf("failed to encode runtime state: %w", err)
+	}
+	return s.update(ctx, runtimeKey, data)
+}
+
+// update sets the data key to data.
+func (s *ConfigMapStore) update(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range maxConflictRetries {
+		cm, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		cm.Data[key] = string(data)
+
+		if cm.Metadata.ResourceVersion == "" {
+			err = s.client.Create(ctx, s.collectionPath(), cm, nil)
+		} else {
+			err = s.client.Update(ctx, s.objectPath(), cm, nil)
+		}
+		if !errors.Is(err, kube.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to update configmap %s/%s after %d conflicts", s.namespace, s.name, maxConflictRetries)
+}
+
+// List returns all records ordered by height.
+func (s *ConfigMapStore) List(ctx context.Context) ([]Record, error) {
+	cm, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string]Record{}
+	for key, data := range cm.Data {
+		if key == runtimeKey {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode record %s: %w", key, err)
+		}
+		records[key] = record
+	}
+	return sortedRecords(records), nil
+}
+
+// load fetches the ConfigMap, returning an empty one without a resourceVersion if it does not exist yet.
+func (s *ConfigMapStore) load(ctx context.Context) (*configMap, error) {
+	var cm configMap
+	err := s.client.Get(ctx, s.objectPath(), &cm)
+	if errors.Is(err, kube.ErrNotFound) {
+		return &configMap{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   kube.ObjectMeta{Name: s.name, Namespace: s.namespace},
+			Data:       map[string]string{},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	return &cm, nil
+}
+
+func (s *ConfigMapStore) collectionPath() string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/configmaps", s.namespace)
+}
+
+func (s *ConfigMapStore) objectPath() string {
+	return fmt.Sprintf("%s/%s", s.collectionPath(), s.name)
+}
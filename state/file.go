@@ -0,0 +1,167 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileStore keeps records in a JSON file, and the runtime state in another one next
+// to it, e.g. state-runtime.json for state.json. Writes go to a temporary file that is
+// renamed over the original, so a crash never leaves a truncated file behind.
+type FileStore struct {
+	mu          sync.Mutex
+	path        string
+	runtimePath string
+}
+
+// NewFileStore creates a new file-backed store. The files are created on first write.
+func NewFileStore(path string) *FileStore {
+	ext := filepath.Ext(path)
+	return &FileStore{
+		path:        path,
+		runtimePath: strings.TrimSuffix(path, ext) + "-runtime" + ext,
+	}
+}
+
+var _ Store = (*FileStore)(nil)
+
+// Get returns the record for the named plan, or nil if it was never promoted.
+func (s *FileStore) Get(_ context.Context, plan string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[plan]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Put stores a record, replacing any previous record for the same plan.
+func (s *FileStore) Put(_ context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	records[record.Plan] = record
+	return s.save(records)
+}
+
+// List returns all records ordered by height.
+func (s *FileStore) List(_ context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedRecords(records), nil
+}
+
+// GetRuntime returns the runtime state, or nil if it was never stored.
+func (s *FileStore) GetRuntime(_ context.Context) (*Runtime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var runtime *Runtime
+	if err := readJSON(s.runtimePath, &runtime); err != nil {
+		return nil, err
+	}
+	return runtime, nil
+}
+
+// PutRuntime stores the runtime state, replacing the previous one.
+func (s *FileStore) PutRuntime(_ context.Context, runtime Runtime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSON(s.runtimePath, runtime)
+}
+
+func (s *FileStore) load() (map[string]Record, error) {
+	records := map[string]Record{}
+	if err := readJSON(s.path, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *FileStore) save(records map[string]Record) error {
+	return writeJSON(s.path, records)
+}
+
+// readJSON decodes the file at path into v, leaving v alone if the file does not exist.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode state file: %w", err)
+	}
+	return nil
+}
+
+// writeJSON atomically replaces the file at path with v encoded as JSON.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+func sortedRecords(records map[string]Record) []Record {
+	list := make([]Record, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Height != list[j].Height {
+			return list[i].Height < list[j].Height
+		}
+		return list[i].Plan < list[j].Plan
+	})
+	return list
+}
@@ -0,0 +1,55 @@
+// Package state records which upgrades gopher-updater has already promoted, so
+// that a tag deleted or moved by hand is not pushed again, and what it was told at
+// run time, so that it outlives a restart.
+package state
+
+import (
+	"context"
+	"time"
+)
+
+// Record describes a promoted upgrade. SourceDigest and TargetTag are those of the
+// first repository; Images lists every repository the upgrade promoted.
+type Record struct {
+	Plan         string        `json:"plan"`
+	Height       int64         `json:"height"`
+	SourceDigest string        `json:"source_digest"`
+	TargetTag    string        `json:"target_tag"`
+	Images       []ImageRecord `json:"images,omitempty"`
+	PromotedAt   time.Time     `json:"promoted_at"`
+}
+
+// ImageRecord describes the image promoted in one repository.
+type ImageRecord struct {
+	Repo         string `json:"repo"`
+	SourceDigest string `json:"source_digest"`
+	TargetTag    string `json:"target_tag"`
+}
+
+// Runtime is what an updater was told at run time, kept so that a restart, or another
+// replica taking over, carries on where it left off.
+type Runtime struct {
+	// Paused tells whether automatic promotion is paused.
+	Paused bool `json:"paused"`
+	// Approvals maps each approved plan to who approved it.
+	Approvals map[string]string `json:"approvals,omitempty"`
+	// Awaiting maps each plan awaiting approval to when it started waiting, which the
+	// approval timeout counts from.
+	Awaiting map[string]time.Time `json:"awaiting,omitempty"`
+	// Notified lists the notifications already sent, so they are not sent again.
+	Notified []string `json:"notified,omitempty"`
+}
+
+// Store persists promotion records and the runtime state.
+type Store interface {
+	// Get returns the record for the named plan, or nil if it was never promoted.
+	Get(ctx context.Context, plan string) (*Record, error)
+	// Put stores a record, replacing any previous record for the same plan.
+	Put(ctx context.Context, record Record) error
+	// List returns all records.
+	List(ctx context.Context) ([]Record, error)
+	// GetRuntime returns the runtime state, or nil if it was never stored.
+	GetRuntime(ctx context.Context) (*Runtime, error)
+	// PutRuntime stores the runtime state, replacing the previous one.
+	PutRuntime(ctx context.Context, runtime Runtime) error
+}
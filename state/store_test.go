@@ -0,0 +1,208 @@
+package state_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gopher-lab/gopher-updater/pkg/kube"
+	"github.com/gopher-lab/gopher-updater/state"
+)
+
+// fakeConfigMapAPI serves a single ConfigMap the way the Kubernetes API server does,
+// including resourceVersion conflicts.
+type fakeConfigMapAPI struct {
+	mu              sync.Mutex
+	cm              map[string]any
+	resourceVersion int
+	conflicts       int
+}
+
+func (f *fakeConfigMapAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const collection = "/api/v1/namespaces/ns/configmaps"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == collection+"/state":
+		if f.cm == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		Expect(json.NewEncoder(w).Encode(f.cm)).To(Succeed())
+	case r.Method == http.MethodPost && r.URL.Path == collection:
+		if f.cm != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(w, r)
+	case r.Method == http.MethodPut && r.URL.Path == collection+"/state":
+		var cm map[string]any
+		Expect(json.NewDecoder(r.Body).Decode(&cm)).To(Succeed())
+		rv := cm["metadata"].(map[string]any)["resourceVersion"]
+		if f.conflicts > 0 || rv != strconv.Itoa(f.resourceVersion) {
+			f.conflicts--
+			f.resourceVersion++ // someone else wrote in between
+			f.cm["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(f.resourceVersion)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.save(cm)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeConfigMapAPI) store(w http.ResponseWriter, r *http.Request) {
+	var cm map[string]any
+	Expect(json.NewDecoder(r.Body).Decode(&cm)).To(Succeed())
+	f.save(cm)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *fakeConfigMapAPI) save(cm map[string]any) {
+	f.resourceVersion++
+	cm["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(f.resourceVersion)
+	f.cm = cm
+}
+
+var _ = Describe("Store", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	// storeBehaviour runs the contract every Store implementation must satisfy.
+	storeBehaviour := func(newStore func() state.Store) {
+		It("should return nil for an unknown plan", func() {
+			record, err := newStore().Get(ctx, "v1.2.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).To(BeNil())
+		})
+
+		It("should round-trip records and list them by height", func() {
+			store := newStore()
+			promotedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			Expect(store.Put(ctx, state.Record{Plan: "v2.0.0", Height: 200, SourceDigest: "sha256:b", TargetTag: "mainnet-v2.0.0", PromotedAt: promotedAt})).To(Succeed())
+			Expect(store.Put(ctx, state.Record{Plan: "v1.0.0", Height: 100, SourceDigest: "sha256:a", TargetTag: "mainnet-v1.0.0", PromotedAt: promotedAt})).To(Succeed())
+
+			record, err := store.Get(ctx, "v2.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).To(Equal(&state.Record{Plan: "v2.0.0", Height: 200, SourceDigest: "sha256:b", TargetTag: "mainnet-v2.0.0", PromotedAt: promotedAt}))
+
+			records, err := store.List(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Plan).To(Equal("v1.0.0"))
+			Expect(records[1].Plan).To(Equal("v2.0.0"))
+		})
+
+		It("should keep the runtime state apart from the records", func() {
+			store := newStore()
+			runtime, err := store.GetRuntime(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(BeNil())
+
+			Expect(store.Put(ctx, state.Record{Plan: "v1.0.0", Height: 100})).To(Succeed())
+			stored := state.Runtime{
+				Paused:    true,
+				Approvals: map[string]string{"v1.1.0": "api:alice"},
+				Notified:  []string{"v1.1.0@110/proposal_passed"},
+			}
+			Expect(store.PutRuntime(ctx, stored)).To(Succeed())
+
+			runtime, err = newStore().GetRuntime(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(&stored))
+			records, err := store.List(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(1))
+		})
+	}
+
+	Describe("FileStore", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "nested", "state.json")
+		})
+
+		storeBehaviour(func() state.Store { return state.NewFileStore(path) })
+
+		It("should persist records across instances", func() {
+			Expect(state.NewFileStore(path).Put(ctx, state.Record{Plan: "v1.0.0", Height: 100})).To(Succeed())
+
+			record, err := state.NewFileStore(path).Get(ctx, "v1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).NotTo(BeNil())
+		})
+	})
+
+	Describe("ConfigMapStore", func() {
+		var (
+			api    *fakeConfigMapAPI
+			server *httptest.Server
+		)
+
+		BeforeEach(func() {
+			api = &fakeConfigMapAPI{}
+			server = httptest.NewServer(api)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newStore := func() state.Store {
+			return state.NewConfigMapStore(kube.NewClient(server.URL, "token", server.Client()), "ns", "state")
+		}
+
+		storeBehaviour(newStore)
+
+		It("should retry on conflicting updates", func() {
+			store := newStore()
+			Expect(store.Put(ctx, state.Record{Plan: "v1.0.0", Height: 100})).To(Succeed())
+
+			api.mu.Lock()
+			api.conflicts = 2
+			api.mu.Unlock()
+			Expect(store.Put(ctx, state.Record{Plan: "v2.0.0", Height: 200})).To(Succeed())
+
+			records, err := store.List(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+		})
+
+		It("should sanitize plan names into valid data keys", func() {
+			store := newStore()
+			Expect(store.Put(ctx, state.Record{Plan: "v1.0.0/rc 1", Height: 100})).To(Succeed())
+
+			record, err := store.Get(ctx, "v1.0.0/rc 1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.Plan).To(Equal("v1.0.0/rc 1"))
+			Expect(api.cm["data"]).To(HaveKey("v1.0.0_2frc_201"))
+		})
+
+		It("should keep plans whose names only differ by escaped characters apart", func() {
+			store := newStore()
+			Expect(store.Put(ctx, state.Record{Plan: "v1/2", Height: 100})).To(Succeed())
+			Expect(store.Put(ctx, state.Record{Plan: "v1_2", Height: 200})).To(Succeed())
+
+			records, err := store.List(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			record, err := store.Get(ctx, "v1/2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.Height).To(Equal(int64(100)))
+		})
+	})
+})
@@ -0,0 +1,276 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// The operations in this file back the admin API. Actions wait for a check in progress
+// to complete, so they never race with a retag or a configuration reload. Plans and Chain
+// only read, so they do not wait for a check, only for a reload being applied.
+
+// Statuses of a plan, see PlanStatus.
+const (
+	// PlanFuture means the upgrade height has not been reached yet.
+	PlanFuture = "future"
+	// PlanPending means the upgrade height was reached but the plan was not promoted yet.
+	PlanPending = "pending"
+	// PlanPromoted means every repository has the target tag of the plan.
+	PlanPromoted = "promoted"
+	// PlanFailed means the latest attempt to promote the plan failed.
+	PlanFailed = "failed"
+	// PlanSkipped means the latest catch-up policy skipped the plan for a later one.
+	PlanSkipped = "skipped"
+	// PlanAwaitingApproval means the upgrade height was reached and the plan waits for a manual approval.
+	PlanAwaitingApproval = "awaiting_approval"
+)
+
+var (
+	// ErrPlanNotFound is returned by Promote and Approve for a plan the chain does not know about.
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrUpgradeNotReached is returned by Promote for a plan whose height was not reached yet.
+	ErrUpgradeNotReached = errors.New("upgrade height not reached")
+)
+
+// PlanStatus is a plan known to the chain and where it stands.
+type PlanStatus struct {
+	Name            string    `json:"name"`
+	Height          int64     `json:"height"`
+	Status          string    `json:"status"`
+	BlocksRemaining int64     `json:"blocks_remaining,omitempty"`
+	PromotedAt      time.Time `json:"promoted_at,omitzero"`
+	// Decision is the latest action taken on the plan since the Updater started, if any.
+	Decision *Decision `json:"decision,omitempty"`
+}
+
+// ChainStatus is the chain height and the time left until the next upgrade.
+type ChainStatus struct {
+	Height            int64 `json:"height"`
+	NextUpgradeHeight int64 `json:"next_upgrade_height,omitempty"`
+	BlocksRemaining   int64 `json:"blocks_remaining,omitempty"`
+	// ETASeconds and EstimatedTime are only set when the block time could be estimated.
+	ETASeconds    int64     `json:"eta_seconds,omitempty"`
+	EstimatedTime time.Time `json:"estimated_time,omitzero"`
+	Paused        bool      `json:"paused"`
+}
+
+// Plans returns the plans confirmed by the upgrade module, sorted by height, with their status.
+func (u *Updater) Plans(ctx context.Context) ([]PlanStatus, error) {
+	u.tryApplyReload(ctx)
+	u.reloadMu.RLock()
+	defer u.reloadMu.RUnlock()
+
+	plans, currentPlan, err := u.knownPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentHeight, err := u.cosmosClient.GetLatestBlockHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block height: %w", err)
+	}
+
+	statuses := make([]PlanStatus, 0, len(plans))
+	for _, plan := range plans {
+		height, err := strconv.ParseInt(plan.Height, 10, 64)
+		if err != nil {
+			continue
+		}
+		confirmed, err := u.isConfirmed(ctx, plan, currentPlan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to confirm plan %s with the upgrade module: %w", plan.Name, err)
+		}
+		if !confirmed {
+			continue
+		}
+
+		status := PlanStatus{Name: plan.Name, Height: height}
+		if decision := u.decision(plan.Name); decision.Action != "" {
+			status.Decision = &decision
+		}
+		if err := u.planStatus(ctx, plan, currentHeight, &status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Height < statuses[j].Height })
+	return statuses, nil
+}
+
+// planStatus fills in the status of plan.
+func (u *Updater) planStatus(ctx context.Context, plan cosmos.Plan, currentHeight int64, status *PlanStatus) error {
+	if !reached(currentHeight, status.Height) {
+		status.Status = PlanFuture
+		status.BlocksRemaining = status.Height - currentHeight
+		return nil
+	}
+
+	if u.store != nil {
+		record, err := u.store.Get(ctx, plan.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read state for plan %s: %w", plan.Name, err)
+		}
+		if record != nil {
+			status.Status = PlanPromoted
+			status.PromotedAt = record.PromotedAt
+			return nil
+		}
+	}
+	if status.Decision != nil {
+		switch status.Decision.Action {
+		case ActionRetagged:
+			status.Status = PlanPromoted
+			return nil
+		case ActionFailed, ActionPartial:
+			status.Status = PlanFailed
+			return nil
+		case ActionAwaitingApproval:
+			status.Status = PlanAwaitingApproval
+			return nil
+		case ActionSkipped:
+			status.Status = PlanSkipped
+			return nil
+		}
+	}
+
+	images, err := u.images(ctx, plan)
+	if err != nil {
+		status.Status = PlanFailed
+		return nil
+	}
+	status.Status = PlanPromoted
+	for _, image := range images {
+		exists, err := u.dockerhubClient.TagExists(ctx, image.repo, image.targetTag)
+		if err != nil {
+			return fmt.Errorf("failed to check if target tag exists for plan %s in %s: %w", plan.Name, image.repo, err)
+		}
+		if !exists {
+			status.Status = PlanPending
+		}
+	}
+	return nil
+}
+
+// Chain returns the current chain height and the estimated time until the next upgrade.
+func (u *Updater) Chain(ctx context.Context) (ChainStatus, error) {
+	u.tryApplyReload(ctx)
+	u.reloadMu.RLock()
+	defer u.reloadMu.RUnlock()
+
+	height, err := u.cosmosClient.GetLatestBlockHeight(ctx)
+	if err != nil {
+		return ChainStatus{}, fmt.Errorf("failed to get latest block height: %w", err)
+	}
+	status := ChainStatus{Height: height, Paused: u.paused.Load()}
+
+	next := u.nextUpgradeHeight.Load()
+	if next <= height {
+		return status, nil
+	}
+	status.NextUpgradeHeight = next
+	status.BlocksRemaining = next - height
+	eta, _, err := u.estimateETA(ctx, height, next)
+	if err != nil {
+		xlog.Debug("failed to estimate time until upgrade", "err", err)
+		return status, nil
+	}
+	status.ETASeconds = int64(eta.Seconds())
+	status.EstimatedTime = time.Now().Add(eta).UTC().Truncate(time.Second)
+	return status, nil
+}
+
+// Recheck makes Run check for upgrades right away instead of waiting for the next poll.
+func (u *Updater) Recheck() {
+	u.wakeUp()
+}
+
+// Promote retags the images of the named plan now, regardless of the catch-up policy
+// and of Pause, and approves it if approval is required. A plan whose height was not
+// reached yet is only promoted when force is set. Dry-run mode is still honoured. It
+// returns the resulting decision.
+func (u *Updater) Promote(ctx context.Context, name string, force bool) (Decision, error) {
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	plan, err := u.findPlan(ctx, name)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	height, err := strconv.ParseInt(plan.Height, 10, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to parse upgrade height of plan %s: %w", plan.Name, err)
+	}
+	currentHeight, err := u.cosmosClient.GetLatestBlockHeight(ctx)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to get latest block height: %w", err)
+	}
+	if !reached(currentHeight, height) && !force {
+		return Decision{}, fmt.Errorf("%w: plan %s is at height %d, the chain is at %d", ErrUpgradeNotReached, plan.Name, height, currentHeight)
+	}
+
+	xlog.Warn("manually promoting upgrade", "target", u.cfg.Target, "plan", plan.Name, "height", height, "current_height", currentHeight)
+	if u.approve(plan.Name, ApprovedByPromote) {
+		u.saveRuntime(ctx)
+	}
+	err = u.processUpgrade(ctx, plan)
+	return u.decision(plan.Name), err
+}
+
+// Pause stops reached upgrades from being promoted automatically until Resume is called.
+// Checks, pre-flight verification, notifications and Promote keep working. The pause is
+// kept in the state store; an error means it took effect but will not survive a restart.
+func (u *Updater) Pause(ctx context.Context) error {
+	u.setPaused(true)
+	xlog.Warn("automatic promotion paused", "target", u.target())
+	return u.persistRuntime(ctx)
+}
+
+// Resume lets reached upgrades be promoted automatically again, starting with a check
+// right away. Like Pause, an error means it took effect but will not survive a restart.
+func (u *Updater) Resume(ctx context.Context) error {
+	u.setPaused(false)
+	xlog.Info("automatic promotion resumed", "target", u.target())
+	u.wakeUp()
+	return u.persistRuntime(ctx)
+}
+
+// target returns the name of the target of the Updater. It may be called while a check is in progress.
+func (u *Updater) target() string {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.cfg.Target
+}
+
+// findPlan returns the known plan named name, or ErrPlanNotFound.
+func (u *Updater) findPlan(ctx context.Context, name string) (*cosmos.Plan, error) {
+	plans, _, err := u.knownPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range plans {
+		if plans[i].Name == name {
+			return &plans[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, name)
+}
+
+// knownPlans returns the passed upgrade plans, including the current plan, and the current plan.
+func (u *Updater) knownPlans(ctx context.Context) ([]cosmos.Plan, *cosmos.Plan, error) {
+	plans, err := u.cosmosClient.GetUpgradePlans(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get upgrade plans: %w", err)
+	}
+	currentPlan, err := u.cosmosClient.GetCurrentPlan(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get current upgrade plan: %w", err)
+	}
+	return mergeCurrentPlan(plans, currentPlan), currentPlan, nil
+}
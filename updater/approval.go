@@ -0,0 +1,164 @@
+package updater
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/notify"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// With REQUIRE_APPROVAL, a reached upgrade whose images were verified is not promoted
+// until it is approved: through Approve, by a file named after the plan in APPROVAL_DIR,
+// or automatically once it has been awaiting approval for APPROVAL_TIMEOUT.
+
+// Approvals that do not come from Approve, see Decision.ApprovedBy.
+const (
+	ApprovedByFile    = "file"
+	ApprovedByTimeout = "timeout"
+	ApprovedByPromote = "promote"
+)
+
+// approvalCheckInterval is how often Run looks for approval files and expired approval timeouts.
+const approvalCheckInterval = 5 * time.Second
+
+// Approve approves the named plan, so it is promoted as soon as its height is reached
+// and its images are verified. by tells who approved it. A plan can be approved before
+// its height is reached. Approvals are kept in the state store, like a pause; an error
+// means the approval took effect but will not survive a restart.
+func (u *Updater) Approve(ctx context.Context, name, by string) error {
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	if _, err := u.findPlan(ctx, name); err != nil {
+		return err
+	}
+	u.wakeUp()
+	if !u.approve(name, by) {
+		return nil
+	}
+	xlog.Info("upgrade approved", "target", u.cfg.Target, "plan", name, "by", by)
+	return u.persistRuntime(ctx)
+}
+
+// approve records that plan was approved by by, unless it already was, and reports whether the approval is new.
+func (u *Updater) approve(plan, by string) bool {
+	u.approvalMu.Lock()
+	defer u.approvalMu.Unlock()
+	if _, ok := u.approvals[plan]; ok {
+		return false
+	}
+	if u.approvals == nil {
+		u.approvals = map[string]string{}
+	}
+	u.approvals[plan] = by
+	return true
+}
+
+// approval returns who approved plan and whether it is approved.
+func (u *Updater) approval(plan string) (string, bool) {
+	u.approvalMu.Lock()
+	defer u.approvalMu.Unlock()
+
+	if by, ok := u.approvals[plan]; ok {
+		return by, true
+	}
+	// Plan names come from the chain, never look outside the approval directory.
+	if u.cfg.ApprovalDir != "" && filepath.IsLocal(plan) && !strings.ContainsAny(plan, `/\`) {
+		if _, err := os.Stat(filepath.Join(u.cfg.ApprovalDir, plan)); err == nil {
+			return ApprovedByFile, true
+		}
+	}
+	if since, ok := u.awaiting[plan]; ok && u.cfg.ApprovalTimeout > 0 && time.Since(since) >= u.cfg.ApprovalTimeout {
+		return ApprovedByTimeout, true
+	}
+	return "", false
+}
+
+// checkApproval reports whether the plan of decision may be promoted. If it is not
+// approved yet, the plan is recorded as awaiting approval, which is announced once.
+func (u *Updater) checkApproval(ctx context.Context, decision *Decision) bool {
+	if !u.cfg.RequireApproval {
+		if u.stopAwaiting(decision.Plan) {
+			u.saveRuntime(ctx)
+		}
+		return true
+	}
+
+	if by, approved := u.approval(decision.Plan); approved {
+		// Remember the approval so a failed retag is retried without approving it again.
+		approvedNow := u.approve(decision.Plan, by)
+		if approvedNow {
+			xlog.Info("upgrade approved", "target", u.cfg.Target, "plan", decision.Plan, "by", by)
+		}
+		if u.stopAwaiting(decision.Plan) || approvedNow {
+			u.saveRuntime(ctx)
+		}
+		decision.ApprovedBy = by
+		return true
+	}
+
+	u.approvalMu.Lock()
+	since, ok := u.awaiting[decision.Plan]
+	if !ok {
+		since = time.Now().UTC()
+		if u.awaiting == nil {
+			u.awaiting = map[string]time.Time{}
+		}
+		u.awaiting[decision.Plan] = since
+	}
+	awaiting := len(u.awaiting)
+	u.approvalMu.Unlock()
+	metrics.AwaitingApproval.WithLabelValues(u.cfg.Target).Set(float64(awaiting))
+	if !ok {
+		// The approval timeout counts from here, even across a restart.
+		u.saveRuntime(ctx)
+	}
+
+	decision.Action = ActionAwaitingApproval
+	decision.AwaitingSince = since
+	event := u.decisionEvent(notify.EventApprovalRequired, *decision)
+	if u.cfg.ApprovalTimeout > 0 {
+		decision.AutoApproveAt = since.Add(u.cfg.ApprovalTimeout)
+		event.AutoApproveIn = time.Until(decision.AutoApproveAt).Round(time.Second)
+	}
+	u.recordDecision(*decision)
+	xlog.Warn("upgrade is awaiting approval", "target", u.cfg.Target, "plan", decision.Plan, "height", decision.Height, "since", since, "auto_approve_at", decision.AutoApproveAt)
+	if u.notifier != nil {
+		u.notifyOnce(ctx, event)
+	}
+	return false
+}
+
+// stopAwaiting forgets that plan was awaiting approval and reports whether it was.
+func (u *Updater) stopAwaiting(plan string) bool {
+	u.approvalMu.Lock()
+	_, ok := u.awaiting[plan]
+	delete(u.awaiting, plan)
+	awaiting := len(u.awaiting)
+	u.approvalMu.Unlock()
+	metrics.AwaitingApproval.WithLabelValues(u.cfg.Target).Set(float64(awaiting))
+	return ok
+}
+
+// approvalDue reports whether a plan awaiting approval has been approved by a file or
+// by its timeout, so Run checks right away.
+func (u *Updater) approvalDue() bool {
+	u.approvalMu.Lock()
+	plans := make([]string, 0, len(u.awaiting))
+	for plan := range u.awaiting {
+		plans = append(plans, plan)
+	}
+	u.approvalMu.Unlock()
+
+	for _, plan := range plans {
+		if _, approved := u.approval(plan); approved {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/notify"
+)
+
+// WithNotifier makes the Updater announce upgrade lifecycle events.
+func WithNotifier(notifier notify.NotifierInterface) Option {
+	return func(u *Updater) {
+		u.notifier = notifier
+	}
+}
+
+// notifyUpcoming announces newly seen upcoming plans and plans whose estimated time
+// until the upgrade crossed one of the reminder thresholds. Each event is sent once
+// per plan, and with a state store, once across restarts and changes of leader.
+func (u *Updater) notifyUpcoming(ctx context.Context, plans []cosmos.Plan, currentHeight int64) {
+	if u.notifier == nil {
+		return
+	}
+
+	for _, plan := range plans {
+		height, err := strconv.ParseInt(plan.Height, 10, 64)
+		if err != nil || reached(currentHeight, height) {
+			continue
+		}
+
+		event := u.newEvent(ctx, notify.EventProposalPassed, plan)
+		event.BlocksRemaining = height - currentHeight
+		u.notifyOnce(ctx, event)
+
+		eta, _, err := u.estimateETA(ctx, currentHeight, height)
+		if err != nil {
+			continue
+		}
+		event.ETA = eta.Round(time.Second)
+		switch {
+		case eta <= 10*time.Minute:
+			// An upgrade first seen this close needs no 1h reminder.
+			event.Type = notify.EventUpgradeIn1h
+			if u.markNotified(event) {
+				u.saveRuntime(ctx)
+			}
+			event.Type = notify.EventUpgradeIn10m
+			u.notifyOnce(ctx, event)
+		case eta <= time.Hour:
+			event.Type = notify.EventUpgradeIn1h
+			u.notifyOnce(ctx, event)
+		}
+	}
+}
+
+// notifyOnce sends event unless it was already sent for its plan, or for a failure,
+// with the same error, so a plan failing the same way on every cycle is announced once.
+func (u *Updater) notifyOnce(ctx context.Context, event notify.Event) {
+	if u.notifier == nil || !u.markNotified(event) {
+		return
+	}
+	u.saveRuntime(ctx)
+	u.notifier.Notify(ctx, event)
+}
+
+// markNotified records that event was sent and reports whether it is new.
+func (u *Updater) markNotified(event notify.Event) bool {
+	u.notifiedMu.Lock()
+	defer u.notifiedMu.Unlock()
+
+	key := fmt.Sprintf("%s@%d/%s", event.Plan, event.Height, event.Type)
+	if event.Error != "" {
+		key += ": " + event.Error
+	}
+	if u.notified[key] {
+		return false
+	}
+	if u.notified == nil {
+		u.notified = map[string]bool{}
+	}
+	u.notified[key] = true
+	return true
+}
+
+// notify sends event if a notifier is configured.
+func (u *Updater) notify(ctx context.Context, event notify.Event) {
+	if u.notifier != nil {
+		u.notifier.Notify(ctx, event)
+	}
+}
+
+// newEvent returns an event for plan listing the images it promotes. Tags that cannot
+// be rendered are left out; the render error is reported when the plan is processed.
+func (u *Updater) newEvent(ctx context.Context, eventType notify.EventType, plan cosmos.Plan) notify.Event {
+	height, _ := strconv.ParseInt(plan.Height, 10, 64)
+	event := notify.Event{Target: u.cfg.Target, Type: eventType, Plan: plan.Name, Height: height}
+	images, _ := u.images(ctx, plan)
+	for _, image := range images {
+		event.Images = append(event.Images, notify.Image{Repo: image.repo, SourceTag: image.sourceTag, TargetTag: image.targetTag})
+	}
+	return event
+}
+
+// decisionEvent returns an event describing decision.
+func (u *Updater) decisionEvent(eventType notify.EventType, decision Decision) notify.Event {
+	event := notify.Event{Target: u.cfg.Target, Type: eventType, Plan: decision.Plan, Height: decision.Height}
+	for _, image := range decision.Images {
+		event.Images = append(event.Images, notify.Image{
+			Repo:      image.Repo,
+			SourceTag: image.SourceTag,
+			TargetTag: image.TargetTag,
+			Digest:    image.Digest,
+			Error:     image.Error,
+		})
+	}
+	return event
+}
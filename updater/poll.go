@@ -0,0 +1,110 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// blockTimeTTL is how long an estimated block time is reused before it is estimated again.
+const blockTimeTTL = 10 * time.Minute
+
+// pollInterval returns how long to wait before the next check.
+//
+// Without adaptive polling this is always PollInterval. With adaptive polling it is half
+// the estimated time until the next upgrade, clamped between the average block time and
+// MaxPollInterval. Polling is therefore slow while an upgrade is days away and tightens
+// to one check per block in the final blocks before the upgrade height.
+func (u *Updater) pollInterval(ctx context.Context, checkErr error) time.Duration {
+	if !u.cfg.AdaptivePolling {
+		return u.cfg.PollInterval
+	}
+
+	interval := u.cfg.MaxPollInterval
+	if next := u.nextUpgradeHeight.Load(); next > 0 {
+		eta, blockTime, err := u.estimateETA(ctx, u.currentHeight.Load(), next)
+		if err != nil {
+			xlog.Warn("failed to estimate time until upgrade, using default poll interval", "err", err)
+			interval = u.cfg.PollInterval
+		} else {
+			interval = max(min(eta/2, u.cfg.MaxPollInterval), blockTime)
+		}
+	}
+	// Retry failed checks at least as often as the non-adaptive interval.
+	if checkErr != nil {
+		interval = min(interval, u.cfg.PollInterval)
+	}
+	interval = max(interval, u.cfg.MinPollInterval)
+
+	xlog.Debug("next upgrade check scheduled", "in", interval)
+	return interval
+}
+
+// recordHeights exports the chain height and the distance to the next upgrade as metrics.
+// next is 0 when no upgrade is upcoming.
+func (u *Updater) recordHeights(ctx context.Context, currentHeight, next int64) {
+	metrics.ChainHeight.WithLabelValues(u.cfg.Target).Set(float64(currentHeight))
+	metrics.NextUpgradeHeight.WithLabelValues(u.cfg.Target).Set(float64(next))
+	if next == 0 {
+		metrics.BlocksRemaining.WithLabelValues(u.cfg.Target).Set(0)
+		metrics.UpgradeETA.WithLabelValues(u.cfg.Target).Set(0)
+		return
+	}
+
+	metrics.BlocksRemaining.WithLabelValues(u.cfg.Target).Set(float64(next - currentHeight))
+	eta, _, err := u.estimateETA(ctx, currentHeight, next)
+	if err != nil {
+		xlog.Debug("failed to estimate time until upgrade", "err", err)
+		metrics.UpgradeETA.WithLabelValues(u.cfg.Target).Set(0)
+		return
+	}
+	metrics.UpgradeETA.WithLabelValues(u.cfg.Target).Set(eta.Seconds())
+}
+
+// estimateETA estimates how long it will take the chain to go from currentHeight to
+// upgradeHeight. It also returns the average block time the estimate is based on.
+func (u *Updater) estimateETA(ctx context.Context, currentHeight, upgradeHeight int64) (time.Duration, time.Duration, error) {
+	blockTime, err := u.estimateBlockTime(ctx, currentHeight)
+	if err != nil {
+		return 0, 0, err
+	}
+	remaining := max(upgradeHeight-currentHeight, 0)
+	return time.Duration(remaining) * blockTime, blockTime, nil
+}
+
+// estimateBlockTime returns the average block time over the last BlockTimeWindow blocks,
+// reusing the previous estimate for blockTimeTTL.
+func (u *Updater) estimateBlockTime(ctx context.Context, currentHeight int64) (time.Duration, error) {
+	u.blockTimeMu.Lock()
+	defer u.blockTimeMu.Unlock()
+
+	if u.blockTime > 0 && time.Since(u.blockTimeAt) < blockTimeTTL {
+		return u.blockTime, nil
+	}
+	if currentHeight < 2 {
+		return 0, errors.New("not enough blocks to estimate block time")
+	}
+
+	window := max(min(int64(u.cfg.BlockTimeWindow), currentHeight-1), 1)
+	latest, err := u.cosmosClient.GetBlockHeader(ctx, currentHeight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block %d: %w", currentHeight, err)
+	}
+	earlier, err := u.cosmosClient.GetBlockHeader(ctx, currentHeight-window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block %d: %w", currentHeight-window, err)
+	}
+
+	blockTime := latest.Time.Sub(earlier.Time) / time.Duration(window)
+	if blockTime <= 0 {
+		return 0, fmt.Errorf("invalid block times between heights %d and %d", currentHeight-window, currentHeight)
+	}
+
+	u.blockTime = blockTime
+	u.blockTimeAt = time.Now()
+	return blockTime, nil
+}
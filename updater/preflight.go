@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PreflightStatus is the result of verifying the source image of an upcoming plan
+// in one repository before its height is reached.
+type PreflightStatus struct {
+	Plan            string    `json:"plan"`
+	Height          int64     `json:"height"`
+	BlocksRemaining int64     `json:"blocks_remaining"`
+	Repo            string    `json:"repo"`
+	SourceTag       string    `json:"source_tag"`
+	Ready           bool      `json:"ready"`
+	Error           string    `json:"error,omitempty"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// preflight verifies that the source images of every upcoming plan have been pushed,
+// so a missing image is noticed before the chain halts rather than at halt height.
+// Plans the upgrade module does not confirm, e.g. superseded by a later proposal, are
+// not verified. Failures are logged and reported, but never fail the check cycle.
+func (u *Updater) preflight(ctx context.Context, plans []cosmos.Plan, currentPlan *cosmos.Plan, currentHeight int64) {
+	var statuses []PreflightStatus
+	metrics.SourceImagePresent.DeletePartialMatch(prometheus.Labels{"target": u.cfg.Target})
+
+	for _, plan := range plans {
+		height, err := strconv.ParseInt(plan.Height, 10, 64)
+		if err != nil || reached(currentHeight, height) {
+			continue
+		}
+		confirmed, err := u.isConfirmed(ctx, plan, currentPlan)
+		if err != nil {
+			xlog.Warn("failed to confirm upcoming upgrade with the upgrade module, not verifying it", "plan", plan.Name, "err", err)
+			continue
+		}
+		if !confirmed {
+			xlog.Debug("upgrade module does not know about upcoming plan, not verifying it", "plan", plan.Name, "height", plan.Height)
+			continue
+		}
+		for _, repo := range u.cfg.Repos() {
+			status := PreflightStatus{
+				Plan:            plan.Name,
+				Height:          height,
+				BlocksRemaining: height - currentHeight,
+				Repo:            repo.Path,
+				CheckedAt:       time.Now().UTC(),
+			}
+			u.preflightImage(ctx, plan, repo, &status)
+			statuses = append(statuses, status)
+		}
+	}
+
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	u.preflightStatuses = statuses
+}
+
+// preflightImage verifies the source image of plan in repo and fills in status.
+func (u *Updater) preflightImage(ctx context.Context, plan cosmos.Plan, repo config.Repository, status *PreflightStatus) {
+	var exists bool
+	sourceTag, _, err := u.tags(ctx, plan, repo)
+	if err == nil {
+		status.SourceTag = sourceTag
+		exists, err = u.dockerhubClient.TagExists(ctx, repo.Path, sourceTag)
+	}
+
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+		xlog.Warn("failed to verify source image of upcoming upgrade", "plan", plan.Name, "repo", repo.Path, "source", status.SourceTag, "err", err)
+		return
+	case !exists:
+		status.Error = "source image not found"
+		if status.BlocksRemaining <= u.cfg.PreflightWarnBlocks {
+			xlog.Error("SOURCE IMAGE MISSING FOR IMMINENT UPGRADE: the retag will fail at the upgrade height unless it is pushed",
+				"plan", plan.Name, "repo", repo.Path, "source", status.SourceTag, "height", status.Height, "blocks_remaining", status.BlocksRemaining)
+		} else {
+			xlog.Warn("source image for upcoming upgrade not pushed yet", "plan", plan.Name, "repo", repo.Path, "source", status.SourceTag, "blocks_remaining", status.BlocksRemaining)
+		}
+	default:
+		status.Ready = true
+	}
+
+	present := 0.0
+	if exists {
+		present = 1
+	}
+	metrics.SourceImagePresent.WithLabelValues(u.cfg.Target, plan.Name, repo.Path).Set(present)
+}
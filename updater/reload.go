@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"context"
+
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/dockerhub"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// reload is a configuration waiting to be applied, see Reload.
+type reload struct {
+	cosmosClient    cosmos.ClientInterface
+	dockerhubClient dockerhub.ClientInterface
+	cfg             *config.Config
+	opts            []Option
+}
+
+// Reload replaces the configuration and clients of the Updater, e.g. after its
+// configuration file changed, and makes Run check right away with them.
+//
+// The swap happens right away unless Run is running, e.g. on a replica that is not
+// the leader; otherwise it happens before the next check, so a check in progress,
+// including its retags, completes with the previous configuration. The options replace the state store and notifier; a block subscriber
+// only takes effect when Run is started again. A different state store has the
+// runtime state restored from it, see restore.
+func (u *Updater) Reload(
+	ctx context.Context,
+	cosmosClient cosmos.ClientInterface,
+	dockerhubClient dockerhub.ClientInterface,
+	cfg *config.Config,
+	opts ...Option,
+) {
+	u.pending.Store(&reload{
+		cosmosClient:    cosmosClient,
+		dockerhubClient: dockerhubClient,
+		cfg:             cfg,
+		opts:            opts,
+	})
+	// A replica that is not the leader does not run, so it must not wait for a check.
+	u.tryApplyReload(ctx)
+	u.wakeUp()
+}
+
+// tryApplyReload swaps in the configuration passed to Reload, if any, unless Run is
+// running, which swaps it in before its next check, or an admin operation is.
+func (u *Updater) tryApplyReload(ctx context.Context) {
+	if u.pending.Load() == nil || !u.runMu.TryLock() {
+		return
+	}
+	defer u.runMu.Unlock()
+	if !u.checkMu.TryLock() {
+		return
+	}
+	defer u.checkMu.Unlock()
+	u.applyReload(ctx)
+}
+
+// wakeUp makes Run check right away. It never blocks; a check that is already
+// requested is not requested twice.
+func (u *Updater) wakeUp() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// applyReload swaps in the configuration passed to Reload, if any. It must be called
+// by a check, or with runMu and checkMu held.
+func (u *Updater) applyReload(ctx context.Context) {
+	r := u.pending.Swap(nil)
+	if r == nil {
+		return
+	}
+
+	u.reloadMu.Lock()
+	u.statusMu.Lock()
+	u.cosmosClient = r.cosmosClient
+	u.dockerhubClient = r.dockerhubClient
+	u.cfg = r.cfg
+	subscriber, store := u.subscriber, u.store
+	u.store, u.notifier = nil, nil
+	for _, opt := range r.opts {
+		opt(u)
+	}
+	u.subscriber = subscriber
+	storeChanged := u.store != store
+	u.statusMu.Unlock()
+	u.reloadMu.Unlock()
+
+	if storeChanged {
+		u.restore(ctx)
+	}
+
+	// The chain endpoints may have changed, so the cached chain facts may be stale.
+	u.chainIDMu.Lock()
+	u.cachedChainID = ""
+	u.chainIDMu.Unlock()
+	u.blockTimeMu.Lock()
+	u.blockTime = 0
+	u.blockTimeMu.Unlock()
+
+	xlog.Info("applied reloaded configuration", "target", u.cfg.Target)
+}
@@ -0,0 +1,101 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/gopher-lab/gopher-updater/state"
+)
+
+// restore loads the runtime state from the state store: the pause, approvals, since
+// when plans await approval, and the notifications already sent. A restart, or another
+// replica taking over, then carries on where the previous one left off. Without a
+// state store, this state is lost on exit.
+//
+// Run calls it each time it starts, since another replica may have changed it in
+// between. A reload calls it when it replaces the state store.
+func (u *Updater) restore(ctx context.Context) {
+	store := u.stateStore()
+	if store == nil {
+		return
+	}
+	runtime, err := store.GetRuntime(ctx)
+	if err != nil {
+		xlog.Error("failed to restore runtime state, keeping the current one", "target", u.target(), "err", err)
+		return
+	}
+	if runtime == nil {
+		return
+	}
+
+	u.setPaused(runtime.Paused)
+	u.approvalMu.Lock()
+	u.approvals = maps.Clone(runtime.Approvals)
+	u.awaiting = maps.Clone(runtime.Awaiting)
+	awaiting := len(u.awaiting)
+	u.approvalMu.Unlock()
+	metrics.AwaitingApproval.WithLabelValues(u.target()).Set(float64(awaiting))
+	u.notifiedMu.Lock()
+	u.notified = make(map[string]bool, len(runtime.Notified))
+	for _, key := range runtime.Notified {
+		u.notified[key] = true
+	}
+	u.notifiedMu.Unlock()
+
+	if runtime.Paused {
+		xlog.Warn("automatic promotion is paused, resume it through the admin api", "target", u.target())
+	}
+}
+
+// persistRuntime stores the runtime state in the state store, if there is one.
+func (u *Updater) persistRuntime(ctx context.Context) error {
+	store := u.stateStore()
+	if store == nil {
+		return nil
+	}
+
+	// The state is read under runtimeMu, so the last write always has the latest one.
+	u.runtimeMu.Lock()
+	defer u.runtimeMu.Unlock()
+	runtime := state.Runtime{Paused: u.paused.Load()}
+	u.approvalMu.Lock()
+	runtime.Approvals = maps.Clone(u.approvals)
+	runtime.Awaiting = maps.Clone(u.awaiting)
+	u.approvalMu.Unlock()
+	u.notifiedMu.Lock()
+	runtime.Notified = slices.Sorted(maps.Keys(u.notified))
+	u.notifiedMu.Unlock()
+	if err := store.PutRuntime(ctx, runtime); err != nil {
+		return fmt.Errorf("failed to store runtime state, it will not survive a restart: %w", err)
+	}
+	return nil
+}
+
+// saveRuntime persists the runtime state where a failure must not stop what is going
+// on, such as a check, so it is only logged.
+func (u *Updater) saveRuntime(ctx context.Context) {
+	if err := u.persistRuntime(ctx); err != nil {
+		xlog.Error("failed to store runtime state", "target", u.target(), "err", err)
+	}
+}
+
+// stateStore returns the state store, or nil if there is none. It may be called while a
+// check is in progress.
+func (u *Updater) stateStore() state.Store {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.store
+}
+
+func (u *Updater) setPaused(paused bool) {
+	u.paused.Store(paused)
+	value := 0.0
+	if paused {
+		value = 1
+	}
+	metrics.Paused.WithLabelValues(u.target()).Set(value)
+}
@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"sort"
+	"time"
+)
+
+// Actions taken on a reached plan, see Decision.
+const (
+	ActionRetagged = "retagged"
+	ActionDryRun   = "dry_run"
+	ActionFailed   = "failed"
+	// ActionPartial means some repositories were retagged and others failed.
+	// The remaining ones are retried on the next check.
+	ActionPartial = "partially_retagged"
+	// ActionSkipped means the latest catch-up policy skipped the plan for a later one.
+	ActionSkipped = "skipped"
+	// ActionAwaitingApproval means the images were verified and wait for a manual approval, see Approve.
+	ActionAwaitingApproval = "awaiting_approval"
+)
+
+// Decision is the latest action taken on a reached plan.
+type Decision struct {
+	Plan   string          `json:"plan"`
+	Height int64           `json:"height"`
+	Images []ImageDecision `json:"images"`
+	Action string          `json:"action"`
+	Error  string          `json:"error,omitempty"`
+	Time   time.Time       `json:"time"`
+
+	// ApprovedBy tells who or what approved the plan when approval is required.
+	ApprovedBy string `json:"approved_by,omitempty"`
+	// AwaitingSince is when the plan started waiting for approval.
+	AwaitingSince time.Time `json:"awaiting_since,omitzero"`
+	// AutoApproveAt is when a plan awaiting approval is approved automatically, if ever.
+	AutoApproveAt time.Time `json:"auto_approve_at,omitzero"`
+}
+
+// ImageDecision is the outcome of a plan for one repository.
+type ImageDecision struct {
+	Repo      string `json:"repo"`
+	SourceTag string `json:"source_tag"`
+	TargetTag string `json:"target_tag"`
+	Digest    string `json:"digest,omitempty"`
+	Promoted  bool   `json:"promoted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Status is a snapshot of what the Updater knows about the chain and its upgrades.
+type Status struct {
+	Target            string            `json:"target,omitempty"`
+	DryRun            bool              `json:"dry_run"`
+	Paused            bool              `json:"paused"`
+	CurrentHeight     int64             `json:"current_height"`
+	NextUpgradeHeight int64             `json:"next_upgrade_height,omitempty"`
+	Preflight         []PreflightStatus `json:"preflight"`
+	Decisions         []Decision        `json:"decisions"`
+}
+
+// Status returns a snapshot of the Updater's view of the chain, as of the last check.
+func (u *Updater) Status() Status {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+
+	decisions := make([]Decision, 0, len(u.decisions))
+	for _, decision := range u.decisions {
+		decisions = append(decisions, decision)
+	}
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Height < decisions[j].Height })
+
+	return Status{
+		Target:            u.cfg.Target,
+		DryRun:            u.cfg.DryRun,
+		Paused:            u.paused.Load(),
+		CurrentHeight:     u.currentHeight.Load(),
+		NextUpgradeHeight: u.nextUpgradeHeight.Load(),
+		Preflight:         append([]PreflightStatus(nil), u.preflightStatuses...),
+		Decisions:         decisions,
+	}
+}
+
+// recordDecision stores decision as the latest one for its plan.
+func (u *Updater) recordDecision(decision Decision) {
+	decision.Time = time.Now().UTC()
+
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.decisions == nil {
+		u.decisions = map[string]Decision{}
+	}
+	u.decisions[decision.Plan] = decision
+}
+
+// decision returns the latest decision for plan, or the zero Decision if there is none.
+func (u *Updater) decision(plan string) Decision {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.decisions[plan]
+}
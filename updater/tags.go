@@ -0,0 +1,149 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gopher-lab/gopher-updater/config"
+	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/pkg/imageref"
+	"github.com/gopher-lab/gopher-updater/pkg/tagtemplate"
+	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+)
+
+// image is what a plan promotes in one repository.
+type image struct {
+	repo      string
+	sourceTag string
+	targetTag string
+}
+
+// images renders the source and target tags of plan for every configured repository.
+func (u *Updater) images(ctx context.Context, plan cosmos.Plan) ([]image, error) {
+	repos := u.cfg.Repos()
+	images := make([]image, len(repos))
+	for i, repo := range repos {
+		sourceTag, targetTag, err := u.tags(ctx, plan, repo)
+		if err != nil {
+			return nil, fmt.Errorf("repository %s: %w", repo.Path, err)
+		}
+		images[i] = image{repo: repo.Path, sourceTag: sourceTag, targetTag: targetTag}
+	}
+	return images, nil
+}
+
+// tags renders the source and target tags of plan in repo. Without a configured template,
+// a tag is its prefix followed by the plan name. If the plan's info names a source
+// image, that is used as the source instead, and may be a digest rather than a tag.
+func (u *Updater) tags(ctx context.Context, plan cosmos.Plan, repo config.Repository) (string, string, error) {
+	height, _ := strconv.ParseInt(plan.Height, 10, 64)
+	data := tagtemplate.NewData(plan.Name, height, func() (string, error) { return u.chainID(ctx) })
+
+	sourceTag, ok, err := sourceFromInfo(plan, repo, u.cfg.RegistryURL)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		sourceTag, err = renderTag(repo.SourceTagTemplate, repo.SourcePrefix, data)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to render source tag for plan %s: %w", plan.Name, err)
+		}
+	}
+	targetTag, err := renderTag(repo.TargetTagTemplate, repo.TargetPrefix, data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render target tag for plan %s: %w", plan.Name, err)
+	}
+	return sourceTag, targetTag, nil
+}
+
+// sourceFromInfo returns the source tag or digest named at the repository's
+// SourceImageInfoPath in the plan's info. It reports false if that is not configured
+// or the plan does not name an image, so the caller falls back to the tag convention.
+// An image that is named but unusable, e.g. in another repository or registry than
+// the one promoted in, is an error rather than a fallback, since governance voted on it.
+func sourceFromInfo(plan cosmos.Plan, repo config.Repository, registryURL string) (string, bool, error) {
+	if repo.SourceImageInfoPath == "" {
+		return "", false, nil
+	}
+	value, ok, err := plan.InfoString(repo.SourceImageInfoPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read source image from info of plan %s: %w", plan.Name, err)
+	}
+	if !ok {
+		xlog.Debug("plan info names no source image, using the tag convention", "plan", plan.Name, "repo", repo.Path, "path", repo.SourceImageInfoPath)
+		return "", false, nil
+	}
+
+	ref, err := imageref.Parse(value)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid source image in info of plan %s: %w", plan.Name, err)
+	}
+	if ref.Repository != "" && ref.Repository != repo.Path {
+		return "", false, fmt.Errorf("source image %q in info of plan %s is not in repository %s, promoting across repositories is not supported", value, plan.Name, repo.Path)
+	}
+	if host := registryHost(registryURL); ref.Registry != "" && normalizeRegistry(ref.Registry) != host {
+		return "", false, fmt.Errorf("source image %q in info of plan %s is not in registry %s, promoting across registries is not supported", value, plan.Name, host)
+	}
+	return ref.Reference(), true, nil
+}
+
+// dockerHub is the registry images are promoted in when REGISTRY_URL is not set.
+const dockerHub = "docker.io"
+
+// registryHost returns the host of the registry images are promoted in.
+func registryHost(registryURL string) string {
+	if registryURL == "" {
+		return dockerHub
+	}
+	if parsed, err := url.Parse(registryURL); err == nil && parsed.Host != "" {
+		return normalizeRegistry(parsed.Host)
+	}
+	return normalizeRegistry(strings.TrimSuffix(registryURL, "/"))
+}
+
+// normalizeRegistry returns the canonical name of a registry host, since Docker Hub
+// is known under several.
+func normalizeRegistry(host string) string {
+	host = strings.ToLower(host)
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHub
+	}
+	return host
+}
+
+func renderTag(text, prefix string, data tagtemplate.Data) (string, error) {
+	if text == "" {
+		text = tagtemplate.PrefixTemplate(prefix)
+	}
+	tmpl, err := tagtemplate.Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Render(data)
+}
+
+// chainID returns the chain ID for tag templates, cached once known. It is only
+// queried when a template uses it.
+func (u *Updater) chainID(ctx context.Context) (string, error) {
+	chainIDClient, ok := u.cosmosClient.(cosmos.ChainIDClient)
+	if !ok {
+		return "", errors.New("the Cosmos client cannot report the chain id")
+	}
+
+	u.chainIDMu.Lock()
+	defer u.chainIDMu.Unlock()
+	if u.cachedChainID != "" {
+		return u.cachedChainID, nil
+	}
+	chainID, err := chainIDClient.GetChainID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chain id: %w", err)
+	}
+	u.cachedChainID = chainID
+	return chainID, nil
+}
@@ -2,15 +2,22 @@ package updater
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopher-lab/gopher-updater/config"
 	"github.com/gopher-lab/gopher-updater/cosmos"
 	"github.com/gopher-lab/gopher-updater/dockerhub"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/notify"
 	"github.com/gopher-lab/gopher-updater/pkg/xlog"
+	"github.com/gopher-lab/gopher-updater/state"
 )
 
 // Updater is responsible for monitoring the chain and retagging images.
@@ -18,6 +25,74 @@ type Updater struct {
 	cosmosClient    cosmos.ClientInterface
 	dockerhubClient dockerhub.ClientInterface
 	cfg             *config.Config
+	subscriber      cosmos.SubscriberInterface
+	store           state.Store
+	notifier        notify.NotifierInterface
+
+	// nextUpgradeHeight is the lowest plan height above the chain height seen
+	// during the last check, or 0 if there is none.
+	nextUpgradeHeight atomic.Int64
+	// currentHeight is the chain height seen during the last check.
+	currentHeight atomic.Int64
+
+	statusMu          sync.Mutex
+	preflightStatuses []PreflightStatus
+	decisions         map[string]Decision
+
+	// notified records which events were already sent, keyed by plan and event type.
+	notifiedMu sync.Mutex
+	notified   map[string]bool
+
+	// cachedChainID is the chain ID used in tag templates, see chainID.
+	chainIDMu     sync.Mutex
+	cachedChainID string
+
+	// blockTime caches the estimated average block time, see estimateBlockTime.
+	blockTimeMu sync.Mutex
+	blockTime   time.Duration
+	blockTimeAt time.Time
+
+	// runMu is held while Run runs, which reads the configuration outside checks.
+	runMu sync.Mutex
+	// checkMu serializes checks with the admin operations, see admin.go.
+	checkMu sync.Mutex
+	// reloadMu lets Plans and Chain use the clients and configuration while a check is
+	// in progress; applyReload swaps them under it, and under checkMu.
+	reloadMu sync.RWMutex
+	// runtimeMu serializes writes of the runtime state, see runtime.go.
+	runtimeMu sync.Mutex
+	// paused stops reached upgrades from being promoted automatically, see Pause.
+	paused atomic.Bool
+
+	// approvals records who approved each plan, and awaiting since when plans wait
+	// for approval, see approval.go.
+	approvalMu sync.Mutex
+	approvals  map[string]string
+	awaiting   map[string]time.Time
+
+	// pending is the configuration passed to Reload, applied before the next check.
+	pending atomic.Pointer[reload]
+	// wake makes Run check right away instead of waiting for the next poll.
+	wake chan struct{}
+}
+
+// Option configures optional behaviour of the Updater.
+type Option func(*Updater)
+
+// WithBlockSubscriber makes Run check for upgrades as soon as a new block reaches
+// the next known upgrade height, instead of waiting for the next poll.
+func WithBlockSubscriber(subscriber cosmos.SubscriberInterface) Option {
+	return func(u *Updater) {
+		u.subscriber = subscriber
+	}
+}
+
+// WithStateStore makes the Updater record every promotion and skip plans that were
+// already promoted, even if their target tag was later deleted or moved.
+func WithStateStore(store state.Store) Option {
+	return func(u *Updater) {
+		u.store = store
+	}
 }
 
 // New creates a new Updater.
@@ -25,45 +100,121 @@ func New(
 	cosmosClient cosmos.ClientInterface,
 	dockerhubClient dockerhub.ClientInterface,
 	cfg *config.Config,
+	opts ...Option,
 ) *Updater {
-	return &Updater{
+	u := &Updater{
 		cosmosClient:    cosmosClient,
 		dockerhubClient: dockerhubClient,
 		cfg:             cfg,
+		wake:            make(chan struct{}, 1),
 	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
 }
 
-// Run starts the updater loop. It checks for upgrades periodically.
+// Run starts the updater loop. It checks for upgrades periodically and, if a block
+// subscriber is configured, as soon as a new block reaches the next upgrade height.
+// Polling continues while subscribed so that plans are refreshed and a dropped
+// subscription does not stall upgrades.
 func (u *Updater) Run(ctx context.Context) error {
-	ticker := time.NewTicker(u.cfg.PollInterval)
-	defer ticker.Stop()
+	u.runMu.Lock()
+	defer u.runMu.Unlock()
+	u.restore(ctx)
+
+	var blocks <-chan int64
+	if u.subscriber != nil {
+		blocks = u.subscriber.Subscribe(ctx)
+	}
 
-	xlog.Info("performing initial check for software upgrade proposal")
-	if err := u.CheckAndProcessUpgrade(ctx); err != nil {
-		xlog.Error("failed to process upgrade on initial check", "err", err)
+	xlog.Info("performing initial check for software upgrade proposal", "target", u.cfg.Target)
+	err := u.CheckAndProcessUpgrade(ctx)
+	if err != nil {
+		xlog.Error("failed to process upgrade on initial check", "target", u.cfg.Target, "err", err)
 	}
 
+	timer := time.NewTimer(u.pollInterval(ctx, err))
+	defer timer.Stop()
+	approvals := time.NewTicker(approvalCheckInterval)
+	defer approvals.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
-			xlog.Info("checking for software upgrade proposal")
+		case <-timer.C:
+			xlog.Info("checking for software upgrade proposal", "target", u.cfg.Target)
+			err := u.CheckAndProcessUpgrade(ctx)
+			if err != nil {
+				xlog.Error("failed to process upgrade", "target", u.cfg.Target, "err", err)
+			}
+			timer.Reset(u.pollInterval(ctx, err))
+		case <-u.wake:
+			xlog.Info("checking for software upgrade proposal on request", "target", u.cfg.Target)
+			err := u.CheckAndProcessUpgrade(ctx)
+			if err != nil {
+				xlog.Error("failed to process upgrade", "target", u.cfg.Target, "err", err)
+			}
+			timer.Reset(u.pollInterval(ctx, err))
+		case <-approvals.C:
+			if !u.approvalDue() {
+				continue
+			}
+			xlog.Info("checking for software upgrade proposal after approval", "target", u.cfg.Target)
+			err := u.CheckAndProcessUpgrade(ctx)
+			if err != nil {
+				xlog.Error("failed to process upgrade", "target", u.cfg.Target, "err", err)
+			}
+			timer.Reset(u.pollInterval(ctx, err))
+		case height, ok := <-blocks:
+			if !ok {
+				blocks = nil
+				continue
+			}
+			next := u.nextUpgradeHeight.Load()
+			if next == 0 || !reached(height, next) {
+				continue
+			}
+			xlog.Info("new block reached upgrade height", "target", u.cfg.Target, "height", height, "upgrade_height", next)
 			if err := u.CheckAndProcessUpgrade(ctx); err != nil {
-				xlog.Error("failed to process upgrade", "err", err)
+				xlog.Error("failed to process upgrade", "target", u.cfg.Target, "err", err)
 			}
 		}
 	}
 }
 
-// CheckAndProcessUpgrade fetches all passed upgrade plans and processes the next available one.
+// CheckAndProcessUpgrade fetches all passed upgrade plans and processes the pending ones
+// according to the configured catch-up policy. A plan that fails does not stop the others;
+// their errors are joined.
+// Only plans that the x/upgrade module confirms, either as the current plan or as an
+// applied plan, are processed; passed proposals that were later cancelled or replaced are ignored.
 func (u *Updater) CheckAndProcessUpgrade(ctx context.Context) error {
-	plans, err := u.cosmosClient.GetUpgradePlans(ctx)
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+	u.applyReload(ctx)
+
+	start := time.Now()
+	err := u.checkAndProcessUpgrade(ctx)
+
+	metrics.CheckDuration.WithLabelValues(u.cfg.Target).Observe(time.Since(start).Seconds())
+	metrics.CheckCycles.WithLabelValues(u.cfg.Target, metrics.Result(err)).Inc()
+	if err == nil {
+		metrics.LastSuccessfulCheck.WithLabelValues(u.cfg.Target).SetToCurrentTime()
+	}
+	return err
+}
+
+func (u *Updater) checkAndProcessUpgrade(ctx context.Context) error {
+	plans, currentPlan, err := u.knownPlans(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get upgrade plans: %w", err)
+		return err
 	}
 
 	if len(plans) == 0 {
+		u.nextUpgradeHeight.Store(0)
+		u.recordHeights(ctx, u.currentHeight.Load(), 0)
+		u.preflight(ctx, nil, nil, 0)
 		xlog.Info("no passed software upgrade proposals found")
 		return nil
 	}
@@ -73,29 +224,52 @@ func (u *Updater) CheckAndProcessUpgrade(ctx context.Context) error {
 		return fmt.Errorf("failed to get latest block height: %w", err)
 	}
 
-	var pendingPlans []cosmos.Plan
+	next := nextUpgradeHeight(plans, currentHeight)
+	u.currentHeight.Store(currentHeight)
+	u.nextUpgradeHeight.Store(next)
+	u.recordHeights(ctx, currentHeight, next)
+	u.preflight(ctx, plans, currentPlan, currentHeight)
+	u.notifyUpcoming(ctx, plans, currentHeight)
+
+	var (
+		pendingPlans []cosmos.Plan
+		errs         []error
+		// doneHeight is the height of the highest plan already promoted.
+		doneHeight int64
+	)
 	for _, plan := range plans {
 		upgradeHeight, err := strconv.ParseInt(plan.Height, 10, 64)
 		if err != nil {
 			xlog.Error("failed to parse upgrade height, skipping plan", "plan", plan.Name, "height", plan.Height, "err", err)
 			continue
 		}
+		if !reached(currentHeight, upgradeHeight) {
+			continue
+		}
 
-		if currentHeight >= upgradeHeight {
-			targetTag := u.cfg.TargetPrefix + plan.Name
-			exists, err := u.dockerhubClient.TagExists(ctx, u.cfg.RepoPath, targetTag)
-			if err != nil {
-				return fmt.Errorf("failed to check if target tag exists for plan %s: %w", plan.Name, err)
-			}
-			if !exists {
-				pendingPlans = append(pendingPlans, plan)
-			}
+		progress, err := u.planProgress(ctx, plan, upgradeHeight, currentPlan)
+		if err != nil {
+			xlog.Error("failed to check plan, skipping it this cycle", "plan", plan.Name, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		switch progress {
+		case planPending:
+			pendingPlans = append(pendingPlans, plan)
+		case planDone:
+			doneHeight = max(doneHeight, upgradeHeight)
 		}
 	}
 
 	if len(pendingPlans) == 0 {
 		xlog.Info("no pending upgrades to process")
-		return nil
+		return errors.Join(errs...)
+	}
+	if u.paused.Load() {
+		for _, plan := range pendingPlans {
+			xlog.Warn("automatic promotion is paused, not promoting pending upgrade", "plan", plan.Name, "height", plan.Height)
+		}
+		return errors.Join(errs...)
 	}
 
 	// Sort by height to process the oldest pending upgrade first
@@ -105,23 +279,317 @@ func (u *Updater) CheckAndProcessUpgrade(ctx context.Context) error {
 		return h1 < h2
 	})
 
-	nextPlan := pendingPlans[0]
-	xlog.Info("found pending upgrade to process", "plan", nextPlan.Name, "height", nextPlan.Height)
+	selected, skipped := selectPlans(pendingPlans, u.cfg.CatchUpPolicy, doneHeight)
+	for _, plan := range skipped {
+		u.skipUpgrade(plan)
+	}
+	for _, plan := range selected {
+		xlog.Info("found pending upgrade to process", "plan", plan.Name, "height", plan.Height)
+		if err := u.processUpgrade(ctx, &plan); err != nil {
+			xlog.Error("failed to process upgrade", "plan", plan.Name, "err", err)
+			errs = append(errs, fmt.Errorf("plan %s: %w", plan.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// selectPlans applies the catch-up policy to the pending plans, which must be sorted by
+// height, and returns the plans to promote and those the policy skips. doneHeight is the
+// height of the highest plan already promoted: with the latest policy, older plans are
+// never promoted after it, since that would move the target tag back to an older image.
+func selectPlans(pendingPlans []cosmos.Plan, policy string, doneHeight int64) (selected, skipped []cosmos.Plan) {
+	switch policy {
+	case config.CatchUpAll:
+		return pendingPlans, nil
+	case config.CatchUpLatest:
+		latest := pendingPlans[len(pendingPlans)-1]
+		if height, _ := strconv.ParseInt(latest.Height, 10, 64); height <= doneHeight {
+			return nil, pendingPlans
+		}
+		return []cosmos.Plan{latest}, pendingPlans[:len(pendingPlans)-1]
+	default:
+		return pendingPlans[:1], nil
+	}
+}
+
+// skipUpgrade records that the catch-up policy skipped plan.
+func (u *Updater) skipUpgrade(plan cosmos.Plan) {
+	if u.decision(plan.Name).Action == ActionSkipped {
+		return
+	}
+	xlog.Info("skipping intermediate upgrade, only the latest is promoted", "plan", plan.Name, "height", plan.Height)
+	height, _ := strconv.ParseInt(plan.Height, 10, 64)
+	u.recordDecision(Decision{Plan: plan.Name, Height: height, Action: ActionSkipped})
+}
+
+// planProgress is where a plan whose height has been reached stands.
+type planProgress int
+
+const (
+	// planIgnored is a plan that was cancelled or replaced, or whose height is not
+	// confirmed by a quorum of nodes yet.
+	planIgnored planProgress = iota
+	// planPending is a plan that still needs to be promoted.
+	planPending
+	// planDone is a plan that was promoted, or handled in dry-run mode.
+	planDone
+)
+
+// planProgress reports whether a plan whose height has been reached still needs to be
+// promoted, or was already.
+func (u *Updater) planProgress(ctx context.Context, plan cosmos.Plan, upgradeHeight int64, currentPlan *cosmos.Plan) (planProgress, error) {
+	confirmed, err := u.isConfirmed(ctx, plan, currentPlan)
+	if err != nil {
+		return planIgnored, fmt.Errorf("failed to confirm plan %s with the upgrade module: %w", plan.Name, err)
+	}
+	if !confirmed {
+		xlog.Warn("upgrade module does not know about plan, it was likely cancelled or replaced; skipping", "plan", plan.Name, "height", plan.Height)
+		return planIgnored, nil
+	}
+
+	if quorum, ok := u.cosmosClient.(cosmos.QuorumChecker); ok {
+		ok, err := quorum.HeightReached(ctx, haltHeight(upgradeHeight))
+		if err != nil {
+			return planIgnored, fmt.Errorf("failed to confirm upgrade height for plan %s with a quorum of nodes: %w", plan.Name, err)
+		}
+		if !ok {
+			xlog.Warn("upgrade height not yet confirmed by a quorum of nodes, waiting", "plan", plan.Name, "height", plan.Height)
+			return planIgnored, nil
+		}
+	}
+
+	// In dry-run mode the target tag is never created, so remember the plan was
+	// handled to let the catch-up policy move on to the next one.
+	if u.cfg.DryRun && u.decision(plan.Name).Action == ActionDryRun {
+		return planDone, nil
+	}
+
+	if u.store != nil {
+		record, err := u.store.Get(ctx, plan.Name)
+		if err != nil {
+			return planIgnored, fmt.Errorf("failed to read state for plan %s: %w", plan.Name, err)
+		}
+		if record != nil {
+			xlog.Debug("plan was already promoted, skipping", "plan", plan.Name, "target", record.TargetTag, "digest", record.SourceDigest, "promoted_at", record.PromotedAt)
+			return planDone, nil
+		}
+	}
+
+	// The plan is pending until every repository has its target tag, so a partially
+	// promoted plan is retried.
+	images, err := u.images(ctx, plan)
+	if err != nil {
+		return planIgnored, err
+	}
+	for _, image := range images {
+		exists, err := u.dockerhubClient.TagExists(ctx, image.repo, image.targetTag)
+		if err != nil {
+			return planIgnored, fmt.Errorf("failed to check if target tag exists for plan %s in %s: %w", plan.Name, image.repo, err)
+		}
+		if !exists {
+			return planPending, nil
+		}
+	}
+	return planDone, nil
+}
+
+// isConfirmed reports whether the upgrade module agrees that plan is (or was) a real upgrade.
+func (u *Updater) isConfirmed(ctx context.Context, plan cosmos.Plan, currentPlan *cosmos.Plan) (bool, error) {
+	if currentPlan != nil && currentPlan.Name == plan.Name && currentPlan.Height == plan.Height {
+		return true, nil
+	}
+
+	appliedHeight, err := u.cosmosClient.GetAppliedPlanHeight(ctx, plan.Name)
+	if err != nil {
+		return false, err
+	}
+	return appliedHeight > 0, nil
+}
+
+// haltHeight returns the height of the last block committed before an upgrade at
+// upgradeHeight. The upgrade module halts the chain while executing the block at the
+// upgrade height, so that block is never committed until the new binary runs.
+func haltHeight(upgradeHeight int64) int64 {
+	return upgradeHeight - 1
+}
+
+// reached reports whether the chain at currentHeight reached the upgrade at
+// upgradeHeight, i.e. committed the last block before it.
+func reached(currentHeight, upgradeHeight int64) bool {
+	return currentHeight >= haltHeight(upgradeHeight)
+}
 
-	return u.processUpgrade(ctx, &nextPlan)
+// nextUpgradeHeight returns the lowest plan height not reached at currentHeight, or 0
+// if there is none.
+func nextUpgradeHeight(plans []cosmos.Plan, currentHeight int64) int64 {
+	var next int64
+	for _, plan := range plans {
+		height, err := strconv.ParseInt(plan.Height, 10, 64)
+		if err != nil || reached(currentHeight, height) {
+			continue
+		}
+		if next == 0 || height < next {
+			next = height
+		}
+	}
+	return next
 }
 
+// mergeCurrentPlan adds the current plan to plans if no proposal carries it,
+// e.g. because the proposal was pruned from the node's state.
+func mergeCurrentPlan(plans []cosmos.Plan, currentPlan *cosmos.Plan) []cosmos.Plan {
+	if currentPlan == nil {
+		return plans
+	}
+	for _, plan := range plans {
+		if plan.Name == currentPlan.Name && plan.Height == currentPlan.Height {
+			return plans
+		}
+	}
+	return append(plans, *currentPlan)
+}
+
+// processUpgrade promotes plan in every repository as a unit: all source images are
+// resolved before any target tag is touched, so a missing image blocks the whole
+// upgrade. Repositories whose target tag already exists, e.g. after an earlier partial
+// failure, are not retagged again.
 func (u *Updater) processUpgrade(ctx context.Context, plan *cosmos.Plan) error {
-	sourceTag := u.cfg.SourcePrefix + plan.Name
-	targetTag := u.cfg.TargetPrefix + plan.Name
+	height, _ := strconv.ParseInt(plan.Height, 10, 64)
+	decision := Decision{Plan: plan.Name, Height: height}
 
-	xlog.Info("retagging image", "repo", u.cfg.RepoPath, "source", sourceTag, "target", targetTag)
+	images, err := u.images(ctx, *plan)
+	if err != nil {
+		return u.failUpgrade(ctx, plan, decision, ActionFailed, err)
+	}
 
-	err := u.dockerhubClient.RetagImage(ctx, u.cfg.RepoPath, sourceTag, targetTag)
+	var errs []error
+	decision.Images = make([]ImageDecision, len(images))
+	for i, image := range images {
+		decision.Images[i] = ImageDecision{Repo: image.repo, SourceTag: image.sourceTag, TargetTag: image.targetTag}
+		if err := u.verifyImage(ctx, image, &decision.Images[i]); err != nil {
+			decision.Images[i].Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", image.repo, err))
+		}
+	}
+	if len(errs) > 0 {
+		return u.failUpgrade(ctx, plan, decision, ActionFailed, fmt.Errorf("not promoting any image: %w", errors.Join(errs...)))
+	}
+	if !u.checkApproval(ctx, &decision) {
+		return nil
+	}
+
+	if u.cfg.DryRun {
+		for _, image := range decision.Images {
+			xlog.Info("dry run: would retag image", "plan", plan.Name, "repo", image.Repo, "source", image.SourceTag, "target", image.TargetTag, "digest", image.Digest, "already_promoted", image.Promoted)
+		}
+		decision.Action = ActionDryRun
+		u.recordDecision(decision)
+		u.notify(ctx, u.decisionEvent(notify.EventRetagPlanned, decision))
+		return nil
+	}
+
+	promoted := 0
+	for i := range decision.Images {
+		image := &decision.Images[i]
+		if !image.Promoted {
+			if err := u.retag(ctx, image); err != nil {
+				image.Error = err.Error()
+				errs = append(errs, fmt.Errorf("%s: %w", image.Repo, err))
+				continue
+			}
+			image.Promoted = true
+		}
+		promoted++
+	}
+	if len(errs) > 0 {
+		action := ActionFailed
+		if promoted > 0 {
+			action = ActionPartial
+		}
+		return u.failUpgrade(ctx, plan, decision, action,
+			fmt.Errorf("promoted %d of %d images, the rest is retried on the next check: %w", promoted, len(decision.Images), errors.Join(errs...)))
+	}
+
+	xlog.Info("successfully promoted upgrade", "plan", plan.Name, "images", len(decision.Images))
+	decision.Action = ActionRetagged
+	u.recordDecision(decision)
+	u.notify(ctx, u.decisionEvent(notify.EventRetagSucceeded, decision))
+
+	if u.store != nil {
+		record := state.Record{
+			Plan:         plan.Name,
+			Height:       height,
+			SourceDigest: decision.Images[0].Digest,
+			TargetTag:    decision.Images[0].TargetTag,
+			PromotedAt:   time.Now().UTC(),
+		}
+		for _, image := range decision.Images {
+			record.Images = append(record.Images, state.ImageRecord{Repo: image.Repo, SourceDigest: image.Digest, TargetTag: image.TargetTag})
+		}
+		if err := u.store.Put(ctx, record); err != nil {
+			return fmt.Errorf("retagged images but failed to record the promotion: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyImage resolves the source digest of image and checks whether its target tag
+// already exists.
+func (u *Updater) verifyImage(ctx context.Context, source image, image *ImageDecision) error {
+	digest, err := u.dockerhubClient.ManifestDigest(ctx, source.repo, source.sourceTag)
 	if err != nil {
-		return fmt.Errorf("failed to retag image: %w", err)
+		return fmt.Errorf("failed to resolve source image: %w", err)
+	}
+	image.Digest = digest
+	// A source pinned by digest must resolve to exactly that manifest.
+	if strings.HasPrefix(source.sourceTag, "sha256:") && digest != source.sourceTag {
+		return fmt.Errorf("source image resolved to %s, expected pinned digest %s", digest, source.sourceTag)
+	}
+
+	exists, err := u.dockerhubClient.TagExists(ctx, source.repo, source.targetTag)
+	if err != nil {
+		return fmt.Errorf("failed to check if target tag exists: %w", err)
+	}
+	image.Promoted = exists
+	return nil
+}
+
+// retag retags image, retrying up to RetagAttempts times. The source is referenced by
+// the digest verified by verifyImage rather than by its tag, so a tag pushed again in
+// between cannot promote, and record, an image that was never verified.
+func (u *Updater) retag(ctx context.Context, image *ImageDecision) error {
+	backoff := u.cfg.RetagBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		xlog.Info("retagging image", "repo", image.Repo, "source", image.SourceTag, "target", image.TargetTag, "digest", image.Digest, "attempt", attempt)
+		err = u.dockerhubClient.RetagImage(ctx, image.Repo, image.Digest, image.TargetTag)
+		if err == nil || attempt >= u.cfg.RetagAttempts || ctx.Err() != nil {
+			break
+		}
+		xlog.Warn("failed to retag image, retrying", "repo", image.Repo, "target", image.TargetTag, "retry_in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
 
-	xlog.Info("successfully retagged image")
+	metrics.Retags.WithLabelValues(u.cfg.Target, metrics.Result(err)).Inc()
+	if err != nil {
+		return fmt.Errorf("failed to retag image: %w", err)
+	}
+	xlog.Info("successfully retagged image", "repo", image.Repo, "target", image.TargetTag)
 	return nil
 }
+
+// failUpgrade records and announces that promoting plan failed with err, and returns err.
+func (u *Updater) failUpgrade(ctx context.Context, plan *cosmos.Plan, decision Decision, action string, err error) error {
+	decision.Action = action
+	decision.Error = err.Error()
+	u.recordDecision(decision)
+	event := u.decisionEvent(notify.EventRetagFailed, decision)
+	event.Error = err.Error()
+	u.notifyOnce(ctx, event)
+	return err
+}
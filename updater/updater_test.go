@@ -3,13 +3,23 @@ package updater_test
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
 	"github.com/gopher-lab/gopher-updater/config"
 	"github.com/gopher-lab/gopher-updater/cosmos"
+	"github.com/gopher-lab/gopher-updater/metrics"
+	"github.com/gopher-lab/gopher-updater/notify"
+	"github.com/gopher-lab/gopher-updater/state"
 	"github.com/gopher-lab/gopher-updater/updater"
 )
 
@@ -24,7 +34,12 @@ var _ = Describe("Updater", func() {
 
 	BeforeEach(func() {
 		ctx = context.Background()
-		mockCosmosClient = &MockCosmosClient{}
+		mockCosmosClient = &MockCosmosClient{
+			// By default the upgrade module confirms every plan as applied.
+			getAppliedPlanHeightFunc: func(ctx context.Context, name string) (int64, error) {
+				return 1, nil
+			},
+		}
 		mockDockerHubClient = &MockDockerHubClient{}
 		cfg = &config.Config{
 			RepoPath:     "my/repo",
@@ -54,10 +69,34 @@ var _ = Describe("Updater", func() {
 
 			retagCalls := mockDockerHubClient.RetagCalls()
 			Expect(retagCalls).To(HaveLen(1))
-			Expect(retagCalls[0].SourceTag).To(Equal("release-v1.2.3"))
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-v1.2.3"))
 			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.3"))
 		})
 
+		It("should retag the verified digest, not whatever the source tag points to later", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+			var resolved atomic.Int32
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				if resolved.Add(1) == 1 {
+					return "sha256:verified", nil
+				}
+				return "sha256:pushed-later", nil
+			}
+			store := state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:verified", TargetTag: "mainnet-v1.2.3"}))
+			record, err := store.Get(ctx, "v1.2.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.SourceDigest).To(Equal("sha256:verified"))
+		})
+
 		It("should process the oldest of multiple pending upgrades", func() {
 			plans := []cosmos.Plan{
 				{Name: "v1.2.4", Height: "110"},
@@ -78,7 +117,7 @@ var _ = Describe("Updater", func() {
 
 			retagCalls := mockDockerHubClient.RetagCalls()
 			Expect(retagCalls).To(HaveLen(1))
-			Expect(retagCalls[0].SourceTag).To(Equal("release-v1.2.3")) // Processes the one with lower height
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-v1.2.3")) // Processes the one with lower height
 			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.3"))
 		})
 
@@ -105,7 +144,7 @@ var _ = Describe("Updater", func() {
 
 			retagCalls := mockDockerHubClient.RetagCalls()
 			Expect(retagCalls).To(HaveLen(1))
-			Expect(retagCalls[0].SourceTag).To(Equal("release-v1.2.4")) // Processes the next one
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-v1.2.4")) // Processes the next one
 			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.4"))
 		})
 
@@ -115,7 +154,7 @@ var _ = Describe("Updater", func() {
 				return plans, nil
 			}
 			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
-				return 99, nil
+				return 98, nil
 			}
 
 			err := up.CheckAndProcessUpgrade(ctx)
@@ -123,6 +162,19 @@ var _ = Describe("Updater", func() {
 			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
 		})
 
+		It("should retag once the chain halts before the upgrade height", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			// The block at the upgrade height is never committed by the old binary.
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 99, nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(1))
+		})
+
 		It("should do nothing if the target tag already exists", func() {
 			plans := []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}
 			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
@@ -150,6 +202,73 @@ var _ = Describe("Updater", func() {
 			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
 		})
 
+		It("should skip a passed plan that the upgrade module does not confirm", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getAppliedPlanHeightFunc = func(ctx context.Context, name string) (int64, error) {
+				return 0, nil // cancelled with MsgCancelUpgrade
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should only retag the plan that replaced an earlier one", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{
+					{Name: "v1.2.3", Height: "100"},
+					{Name: "v1.2.3-fix", Height: "100"},
+				}, nil
+			}
+			mockCosmosClient.getCurrentPlanFunc = func(ctx context.Context) (*cosmos.Plan, error) {
+				return &cosmos.Plan{Name: "v1.2.3-fix", Height: "100"}, nil
+			}
+			mockCosmosClient.getAppliedPlanHeightFunc = func(ctx context.Context, name string) (int64, error) {
+				return 0, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 100, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-v1.2.3-fix"))
+		})
+
+		It("should process the current plan even if no proposal carries it", func() {
+			mockCosmosClient.getCurrentPlanFunc = func(ctx context.Context) (*cosmos.Plan, error) {
+				return &cosmos.Plan{Name: "v1.2.3", Height: "100"}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 100, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+		})
+
+		It("should return an error if getting the current plan fails", func() {
+			mockCosmosClient.getCurrentPlanFunc = func(ctx context.Context) (*cosmos.Plan, error) {
+				return nil, errors.New("upgrade boom")
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("upgrade boom"))
+		})
+
 		It("should return an error if getting upgrade plans fails", func() {
 			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
 				return nil, errors.New("cosmos boom")
@@ -160,57 +279,1370 @@ var _ = Describe("Updater", func() {
 			Expect(err.Error()).To(ContainSubstring("cosmos boom"))
 		})
 	})
-})
 
-// MockCosmosClient is a mock implementation of the Cosmos client for testing.
-type MockCosmosClient struct {
-	getUpgradePlansFunc      func(ctx context.Context) ([]cosmos.Plan, error)
-	getLatestBlockHeightFunc func(ctx context.Context) (int64, error)
-}
+	Context("when subscribed to new blocks", func() {
+		It("should check for upgrades as soon as the last block before the upgrade height arrives", func() {
+			var height atomic.Int64
+			height.Store(98)
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return height.Load(), nil
+			}
 
-func (m *MockCosmosClient) GetUpgradePlans(ctx context.Context) ([]cosmos.Plan, error) {
-	if m.getUpgradePlansFunc != nil {
-		return m.getUpgradePlansFunc(ctx)
-	}
-	return nil, nil
-}
+			blocks := make(chan int64)
+			cfg.PollInterval = time.Hour
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithBlockSubscriber(&MockSubscriber{blocks: blocks}))
 
-func (m *MockCosmosClient) GetLatestBlockHeight(ctx context.Context) (int64, error) {
-	if m.getLatestBlockHeightFunc != nil {
-		return m.getLatestBlockHeightFunc(ctx)
-	}
-	return 0, nil
-}
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = up.Run(runCtx) }()
 
-// MockDockerHubClient is a mock implementation of the DockerHub client for testing.
-type MockDockerHubClient struct {
-	mu            sync.Mutex
-	retagCalls    []RetagCall
-	tagExistsFunc func(ctx context.Context, repoPath, tag string) (bool, error)
-}
+			// A block further below the upgrade height must not trigger a retag.
+			blocks <- 98
+			Consistently(mockDockerHubClient.RetagCalls, 100*time.Millisecond).Should(BeEmpty())
 
-type RetagCall struct {
-	RepoPath  string
-	SourceTag string
-	TargetTag string
-}
+			// The chain halts after this block, so no event for the upgrade height follows.
+			height.Store(99)
+			blocks <- 99
+			Eventually(mockDockerHubClient.RetagCalls).Should(HaveLen(1))
+		})
+	})
 
-func (m *MockDockerHubClient) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.retagCalls = append(m.retagCalls, RetagCall{RepoPath: repoPath, SourceTag: sourceTag, TargetTag: targetTag})
-	return nil
-}
+	Context("with adaptive polling", func() {
+		var planChecks atomic.Int32
 
-func (m *MockDockerHubClient) TagExists(ctx context.Context, repoPath, tag string) (bool, error) {
-	if m.tagExistsFunc != nil {
-		return m.tagExistsFunc(ctx, repoPath, tag)
-	}
-	return false, nil
-}
+		BeforeEach(func() {
+			planChecks.Store(0)
+			cfg.PollInterval = time.Hour
+			cfg.AdaptivePolling = true
+			cfg.MinPollInterval = time.Millisecond
+			cfg.MaxPollInterval = time.Hour
+			cfg.BlockTimeWindow = 10
 
-func (m *MockDockerHubClient) RetagCalls() []RetagCall {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	return m.retagCalls
+			genesis := time.Now()
+			mockCosmosClient.getBlockHeaderFunc = func(ctx context.Context, height int64) (*cosmos.BlockHeader, error) {
+				// One block every 10ms.
+				return &cosmos.BlockHeader{
+					Height: strconv.FormatInt(height, 10),
+					Time:   genesis.Add(time.Duration(height) * 10 * time.Millisecond),
+				}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 1000, nil
+			}
+		})
+
+		runFor := func(d time.Duration) {
+			runCtx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			_ = up.Run(runCtx)
+		}
+
+		It("should poll every block when the upgrade is imminent", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				planChecks.Add(1)
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "1002"}}, nil
+			}
+
+			runFor(200 * time.Millisecond)
+			Expect(planChecks.Load()).To(BeNumerically(">", 5))
+		})
+
+		It("should poll slowly when the upgrade is far away", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				planChecks.Add(1)
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100000000"}}, nil
+			}
+
+			runFor(200 * time.Millisecond)
+			Expect(planChecks.Load()).To(BeEquivalentTo(1))
+		})
+	})
+
+	Context("with a quorum of nodes", func() {
+		var quorumClient *MockQuorumClient
+
+		BeforeEach(func() {
+			quorumClient = &MockQuorumClient{MockCosmosClient: mockCosmosClient}
+			quorumClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			quorumClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 100, nil
+			}
+			up = updater.New(quorumClient, mockDockerHubClient, cfg)
+		})
+
+		It("should not retag until a quorum of nodes reached the upgrade height", func() {
+			quorumClient.heightReachedFunc = func(ctx context.Context, height int64) (bool, error) {
+				Expect(height).To(BeEquivalentTo(99))
+				return false, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should retag once a quorum of nodes reached the upgrade height", func() {
+			quorumClient.heightReachedFunc = func(ctx context.Context, height int64) (bool, error) {
+				return true, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(1))
+		})
+	})
+
+	Context("with a state store", func() {
+		var store *state.FileStore
+
+		BeforeEach(func() {
+			store = state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+		})
+
+		It("should record the promotion after retagging", func() {
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				Expect(tag).To(Equal("release-v1.2.3"))
+				return "sha256:abc", nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(1))
+
+			record, err := store.Get(ctx, "v1.2.3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(record).ToNot(BeNil())
+			Expect(record.Height).To(BeEquivalentTo(100))
+			Expect(record.SourceDigest).To(Equal("sha256:abc"))
+			Expect(record.TargetTag).To(Equal("mainnet-v1.2.3"))
+			Expect(record.PromotedAt).ToNot(BeZero())
+		})
+
+		It("should not retag a recorded plan even if its target tag is missing", func() {
+			Expect(store.Put(ctx, state.Record{Plan: "v1.2.3", Height: 100, TargetTag: "mainnet-v1.2.3"})).To(Succeed())
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+	})
+
+	Context("when catching up on several reached upgrades", func() {
+		BeforeEach(func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{
+					{Name: "v1.2.5", Height: "120"},
+					{Name: "v1.2.3", Height: "100"},
+					{Name: "v1.2.4", Height: "110"},
+				}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 121, nil
+			}
+		})
+
+		It("should promote all plans in order with the all policy", func() {
+			cfg.CatchUpPolicy = config.CatchUpAll
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(3))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+			Expect(retagCalls[1].TargetTag).To(Equal("mainnet-v1.2.4"))
+			Expect(retagCalls[2].TargetTag).To(Equal("mainnet-v1.2.5"))
+		})
+
+		It("should keep promoting the remaining plans when one fails", func() {
+			cfg.CatchUpPolicy = config.CatchUpAll
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				if sourceTag == "sha256:release-v1.2.4" {
+					return errors.New("registry boom")
+				}
+				return nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("v1.2.4"))
+			Expect(err.Error()).To(ContainSubstring("registry boom"))
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(3))
+		})
+
+		It("should keep checking the remaining plans when checking one fails", func() {
+			cfg.CatchUpPolicy = config.CatchUpAll
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				if tag == "mainnet-v1.2.3" {
+					return false, errors.New("registry boom")
+				}
+				return false, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(2))
+		})
+
+		It("should promote only the latest plan with the latest policy", func() {
+			cfg.CatchUpPolicy = config.CatchUpLatest
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.5"))
+		})
+
+		It("should never promote a skipped plan after the latest one with the latest policy", func() {
+			cfg.CatchUpPolicy = config.CatchUpLatest
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				for _, call := range mockDockerHubClient.RetagCalls() {
+					if call.TargetTag == tag {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.5"))
+
+			actions := map[string]string{}
+			for _, decision := range up.Status().Decisions {
+				actions[decision.Plan] = decision.Action
+			}
+			Expect(actions).To(Equal(map[string]string{
+				"v1.2.3": updater.ActionSkipped,
+				"v1.2.4": updater.ActionSkipped,
+				"v1.2.5": updater.ActionRetagged,
+			}))
+		})
+	})
+
+	Context("when verifying upcoming upgrades", func() {
+		BeforeEach(func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{
+					{Name: "v1.2.3", Height: "100"},
+					{Name: "v1.2.4", Height: "200"},
+					{Name: "v1.2.5", Height: "5000"},
+				}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 150, nil
+			}
+			cfg.PreflightWarnBlocks = 1000
+		})
+
+		It("should report whether the source image of each future plan exists", func() {
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				return tag == "release-v1.2.4" || tag == "mainnet-v1.2.3", nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			status := up.Status()
+			Expect(status.CurrentHeight).To(Equal(int64(150)))
+			Expect(status.NextUpgradeHeight).To(Equal(int64(200)))
+			Expect(status.Preflight).To(HaveLen(2))
+			Expect(status.Preflight[0].Plan).To(Equal("v1.2.4"))
+			Expect(status.Preflight[0].SourceTag).To(Equal("release-v1.2.4"))
+			Expect(status.Preflight[0].BlocksRemaining).To(Equal(int64(50)))
+			Expect(status.Preflight[0].Ready).To(BeTrue())
+			Expect(status.Preflight[1].Plan).To(Equal("v1.2.5"))
+			Expect(status.Preflight[1].Ready).To(BeFalse())
+			Expect(status.Preflight[1].Error).To(Equal("source image not found"))
+		})
+
+		It("should not fail the cycle when the registry cannot be queried", func() {
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				if tag == "mainnet-v1.2.3" {
+					return true, nil
+				}
+				return false, errors.New("registry boom")
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			status := up.Status()
+			Expect(status.Preflight).To(HaveLen(2))
+			Expect(status.Preflight[0].Ready).To(BeFalse())
+			Expect(status.Preflight[0].Error).To(ContainSubstring("registry boom"))
+		})
+
+		It("should only verify the plans confirmed by the upgrade module", func() {
+			mockCosmosClient.getCurrentPlanFunc = func(ctx context.Context) (*cosmos.Plan, error) {
+				return &cosmos.Plan{Name: "v1.2.5", Height: "5000"}, nil
+			}
+			mockCosmosClient.getAppliedPlanHeightFunc = func(ctx context.Context, name string) (int64, error) {
+				if name == "v1.2.3" {
+					return 100, nil
+				}
+				return 0, nil
+			}
+			var checked []string
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				checked = append(checked, tag)
+				return true, nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			status := up.Status()
+			Expect(status.Preflight).To(HaveLen(1))
+			Expect(status.Preflight[0].Plan).To(Equal("v1.2.5"))
+			Expect(checked).NotTo(ContainElement("release-v1.2.4"))
+		})
+
+		It("should clear the status once no plan is upcoming", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.Status().Preflight).To(HaveLen(2))
+
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 6000, nil
+			}
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				return true, nil
+			}
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.Status().Preflight).To(BeEmpty())
+		})
+	})
+
+	Context("when exporting metrics", func() {
+		BeforeEach(func() {
+			cfg.Target = "testnet"
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{
+					{Name: "v1.2.3", Height: "100"},
+					{Name: "v1.2.4", Height: "200"},
+				}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 150, nil
+			}
+		})
+
+		It("should export the chain and upgrade heights", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(gaugeValue(metrics.ChainHeight.WithLabelValues("testnet"))).To(BeEquivalentTo(150))
+			Expect(gaugeValue(metrics.NextUpgradeHeight.WithLabelValues("testnet"))).To(BeEquivalentTo(200))
+			Expect(gaugeValue(metrics.BlocksRemaining.WithLabelValues("testnet"))).To(BeEquivalentTo(50))
+			Expect(gaugeValue(metrics.LastSuccessfulCheck.WithLabelValues("testnet"))).To(BeNumerically(">", 0))
+		})
+
+		It("should keep the source image metrics of other targets", func() {
+			metrics.SourceImagePresent.WithLabelValues("mainnet", "v9.9.9", "my/repo").Set(1)
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				return tag == "release-v1.2.4", nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(gaugeValue(metrics.SourceImagePresent.WithLabelValues("testnet", "v1.2.4", "my/repo"))).To(BeEquivalentTo(1))
+			Expect(gaugeValue(metrics.SourceImagePresent.WithLabelValues("mainnet", "v9.9.9", "my/repo"))).To(BeEquivalentTo(1))
+		})
+
+		It("should count retags and check cycles by result", func() {
+			successes := counterValue(metrics.Retags.WithLabelValues("testnet", metrics.ResultSuccess))
+			failures := counterValue(metrics.Retags.WithLabelValues("testnet", metrics.ResultFailure))
+			failedCycles := counterValue(metrics.CheckCycles.WithLabelValues("testnet", metrics.ResultFailure))
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				return errors.New("registry boom")
+			}
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			Expect(counterValue(metrics.Retags.WithLabelValues("testnet", metrics.ResultSuccess))).To(Equal(successes + 1))
+			Expect(counterValue(metrics.Retags.WithLabelValues("testnet", metrics.ResultFailure))).To(Equal(failures + 1))
+			Expect(counterValue(metrics.CheckCycles.WithLabelValues("testnet", metrics.ResultFailure))).To(Equal(failedCycles + 1))
+		})
+	})
+
+	Context("with a notifier", func() {
+		var notifier *MockNotifier
+
+		BeforeEach(func() {
+			notifier = &MockNotifier{}
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithNotifier(notifier))
+
+			genesis := time.Now()
+			mockCosmosClient.getBlockHeaderFunc = func(ctx context.Context, height int64) (*cosmos.BlockHeader, error) {
+				// One block every second.
+				return &cosmos.BlockHeader{
+					Height: strconv.FormatInt(height, 10),
+					Time:   genesis.Add(time.Duration(height) * time.Second),
+				}, nil
+			}
+			cfg.BlockTimeWindow = 10
+		})
+
+		It("should announce an upcoming plan once", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100000"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 1000, nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			events := notifier.Events()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(notify.EventProposalPassed))
+			Expect(events[0].Plan).To(Equal("v1.2.3"))
+			Expect(events[0].BlocksRemaining).To(BeEquivalentTo(99000))
+			Expect(events[0].Images[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+		})
+
+		It("should remind once when the upgrade is an hour and ten minutes away", func() {
+			var height atomic.Int64
+			height.Store(100000 - 3000)
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100000"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return height.Load(), nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			height.Store(100000 - 500)
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			var types []notify.EventType
+			for _, event := range notifier.Events() {
+				types = append(types, event.Type)
+			}
+			Expect(types).To(Equal([]notify.EventType{notify.EventProposalPassed, notify.EventUpgradeIn1h, notify.EventUpgradeIn10m}))
+			Expect(notifier.Events()[1].ETA).To(Equal(50 * time.Minute))
+		})
+
+		It("should notify about successful and failed retags", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}, {Name: "v1.2.4", Height: "110"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 120, nil
+			}
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				if sourceTag == "sha256:release-v1.2.4" {
+					return errors.New("registry boom")
+				}
+				return nil
+			}
+			cfg.CatchUpPolicy = config.CatchUpAll
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			events := notifier.Events()
+			Expect(events).To(HaveLen(2))
+			Expect(events[0].Type).To(Equal(notify.EventRetagSucceeded))
+			Expect(events[0].Images[0].Digest).To(Equal("sha256:release-v1.2.3"))
+			Expect(events[1].Type).To(Equal(notify.EventRetagFailed))
+			Expect(events[1].Plan).To(Equal("v1.2.4"))
+			Expect(events[1].Error).To(ContainSubstring("registry boom"))
+		})
+
+		It("should notify about a plan failing the same way only once", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 120, nil
+			}
+			var failure atomic.Value
+			failure.Store("registry boom")
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				return errors.New(failure.Load().(string))
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+			failure.Store("registry crash")
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			var errs []string
+			for _, event := range notifier.Events() {
+				Expect(event.Type).To(Equal(notify.EventRetagFailed))
+				errs = append(errs, event.Error)
+			}
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0]).To(ContainSubstring("registry boom"))
+			Expect(errs[1]).To(ContainSubstring("registry crash"))
+		})
+
+		It("should not notify again after a restart or a change of leader with a state store", func() {
+			var height atomic.Int64
+			height.Store(100000 - 3000)
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100000"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return height.Load(), nil
+			}
+			store := state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithNotifier(notifier), updater.WithStateStore(store))
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(notifier.Events()).To(HaveLen(2))
+
+			cfg.PollInterval = time.Hour
+			restarted := updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithNotifier(notifier), updater.WithStateStore(store))
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = restarted.Run(runCtx) }()
+			Eventually(func() int64 { return restarted.Status().CurrentHeight }).Should(Equal(height.Load()))
+			Consistently(notifier.Events, 100*time.Millisecond).Should(HaveLen(2))
+
+			height.Store(100000 - 500)
+			Expect(restarted.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			var types []notify.EventType
+			for _, event := range notifier.Events() {
+				types = append(types, event.Type)
+			}
+			Expect(types).To(Equal([]notify.EventType{notify.EventProposalPassed, notify.EventUpgradeIn1h, notify.EventUpgradeIn10m}))
+		})
+	})
+
+	Context("in dry-run mode", func() {
+		BeforeEach(func() {
+			cfg.DryRun = true
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}, {Name: "v1.2.4", Height: "110"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 120, nil
+			}
+		})
+
+		It("should resolve the source image but not retag it", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+
+			status := up.Status()
+			Expect(status.DryRun).To(BeTrue())
+			Expect(status.Decisions).To(HaveLen(1))
+			Expect(status.Decisions[0].Plan).To(Equal("v1.2.3"))
+			Expect(status.Decisions[0].Action).To(Equal(updater.ActionDryRun))
+			Expect(status.Decisions[0].Images[0].Digest).To(Equal("sha256:release-v1.2.3"))
+			Expect(status.Decisions[0].Images[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+		})
+
+		It("should move on to the next plan on the following cycle", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			decisions := up.Status().Decisions
+			Expect(decisions).To(HaveLen(2))
+			Expect(decisions[1].Plan).To(Equal("v1.2.4"))
+			Expect(decisions[1].Action).To(Equal(updater.ActionDryRun))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should report a missing source image as failed", func() {
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				return "", errors.New("manifest unknown")
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			decisions := up.Status().Decisions
+			Expect(decisions).To(HaveLen(1))
+			Expect(decisions[0].Action).To(Equal(updater.ActionFailed))
+			Expect(decisions[0].Error).To(ContainSubstring("manifest unknown"))
+		})
+	})
+
+	Context("with tag templates", func() {
+		var chainIDClient *MockChainIDClient
+
+		BeforeEach(func() {
+			chainIDClient = &MockChainIDClient{MockCosmosClient: mockCosmosClient, chainID: "gopher-1"}
+			up = updater.New(chainIDClient, mockDockerHubClient, cfg)
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v2", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+		})
+
+		It("should render the source and target tags from the plan", func() {
+			cfg.SourceTagTemplate = "release-v{{.Major}}.{{.Minor}}.{{.Patch}}"
+			cfg.TargetTagTemplate = `{{.ChainID}}-{{.Name | replace "^v" ""}}-{{.Height}}`
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-v2.0.0"))
+			Expect(retagCalls[0].TargetTag).To(Equal("gopher-1-2-100"))
+		})
+
+		It("should sanitize and lowercase plan names", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "Gopher Upgrade/2", Height: "100"}}, nil
+			}
+			cfg.SourceTagTemplate = "release-{{.Name | sanitize | lower}}"
+			cfg.TargetTagTemplate = "mainnet-{{.Name | sanitize | lower}}"
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].SourceTag).To(Equal("sha256:release-gopher-upgrade-2"))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-gopher-upgrade-2"))
+		})
+
+		It("should refuse to retag when a rendered tag is not a valid tag", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "Gopher Upgrade/2", Height: "100"}}, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid tag"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should query the chain id only once", func() {
+			cfg.TargetTagTemplate = "{{.ChainID}}-{{.Name}}"
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(chainIDClient.calls.Load()).To(BeEquivalentTo(1))
+		})
+
+		It("should not query the chain id when no template uses it", func() {
+			cfg.TargetTagTemplate = "mainnet-{{.Name}}"
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(chainIDClient.calls.Load()).To(BeZero())
+		})
+
+		It("should refuse to retag when the chain id cannot be resolved", func() {
+			chainIDClient.err = errors.New("node boom")
+			cfg.TargetTagTemplate = "{{.Name}}-{{.ChainID}}"
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("node boom"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+
+			chainIDClient.err = nil
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v2", TargetTag: "v2-gopher-1"}))
+		})
+	})
+
+	Context("with the source image in the plan info", func() {
+		const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+		BeforeEach(func() {
+			cfg.SourceImageInfoPath = "docker.image"
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+		})
+
+		withInfo := func(info string) {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100", Info: info}}, nil
+			}
+		}
+
+		It("should promote the pinned digest", func() {
+			withInfo(`{"docker": {"image": "my/repo@` + digest + `"}}`)
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				Expect(tag).To(Equal(digest))
+				return digest, nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			Expect(retagCalls).To(HaveLen(1))
+			Expect(retagCalls[0].SourceTag).To(Equal(digest))
+			Expect(retagCalls[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+		})
+
+		It("should use a tag named in the info", func() {
+			withInfo(`{"docker": {"image": "docker.io/my/repo:rc-7"}}`)
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()[0].SourceTag).To(Equal("sha256:rc-7"))
+		})
+
+		It("should refuse a digest that resolves to a different manifest", func() {
+			withInfo(`{"docker": {"image": "` + digest + `"}}`)
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				return "sha256:other", nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("expected pinned digest"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should refuse an image from another repository", func() {
+			withInfo(`{"docker": {"image": "other/repo:v1.2.3"}}`)
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not in repository my/repo"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should refuse an image from another registry", func() {
+			withInfo(`{"docker": {"image": "ghcr.io/my/repo:v1.2.3"}}`)
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not in registry docker.io"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should accept an image from the configured registry", func() {
+			cfg.RegistryURL = "https://GHCR.io/"
+			withInfo(`{"docker": {"image": "ghcr.io/my/repo:rc-7"}}`)
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			cfg.RegistryURL = ""
+			withInfo(`{"docker": {"image": "index.docker.io/my/repo:rc-7"}}`)
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+		})
+
+		It("should fall back to the prefix convention when the info names no image", func() {
+			withInfo(`https://example.com/upgrade-info.json`)
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()[0].SourceTag).To(Equal("sha256:release-v1.2.3"))
+		})
+	})
+
+	Context("when reloading the configuration", func() {
+		var (
+			reloaded    *MockDockerHubClient
+			reloadedCfg *config.Config
+		)
+
+		BeforeEach(func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+			reloaded = &MockDockerHubClient{}
+			reloadedCfg = &config.Config{
+				RepoPath:     "my/repo",
+				SourcePrefix: "release-",
+				TargetPrefix: "testnet-",
+			}
+		})
+
+		It("should check with the reloaded configuration and clients", func() {
+			up.Reload(ctx, mockCosmosClient, reloaded, reloadedCfg)
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+			Expect(reloaded.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v1.2.3", TargetTag: "testnet-v1.2.3"}))
+		})
+
+		It("should finish a retag in progress with the previous configuration", func() {
+			started, release := make(chan struct{}), make(chan struct{})
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				close(started)
+				<-release
+				return nil
+			}
+			done := make(chan error, 1)
+			go func() { done <- up.CheckAndProcessUpgrade(ctx) }()
+
+			Eventually(started).Should(BeClosed())
+			up.Reload(ctx, mockCosmosClient, reloaded, reloadedCfg)
+			close(release)
+
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v1.2.3", TargetTag: "mainnet-v1.2.3"}))
+			Expect(reloaded.RetagCalls()).To(BeEmpty())
+		})
+
+		It("should apply the reloaded configuration without waiting for a check", func() {
+			reloadedCfg.DryRun = true
+			up.Reload(ctx, mockCosmosClient, reloaded, reloadedCfg)
+			Expect(up.Status().DryRun).To(BeTrue())
+		})
+
+		It("should restore the runtime state from a new state store", func() {
+			store := state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			Expect(store.PutRuntime(ctx, state.Runtime{Paused: true, Approvals: map[string]string{"v1.2.3": "api:alice"}})).To(Succeed())
+			reloadedCfg.RequireApproval = true
+
+			up.Reload(ctx, mockCosmosClient, reloaded, reloadedCfg, updater.WithStateStore(store))
+			Expect(up.Status().Paused).To(BeTrue())
+
+			Expect(up.Resume(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(reloaded.RetagCalls()).To(HaveLen(1))
+			Expect(up.Status().Decisions[0].ApprovedBy).To(Equal("api:alice"))
+		})
+
+		It("should make Run check right away", func() {
+			cfg.PollInterval = time.Hour
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = up.Run(runCtx) }()
+			Eventually(mockDockerHubClient.RetagCalls).Should(HaveLen(1))
+
+			reloadedCfg.PollInterval = time.Hour
+			up.Reload(ctx, mockCosmosClient, reloaded, reloadedCfg)
+			Eventually(reloaded.RetagCalls).Should(HaveLen(1))
+		})
+	})
+
+	Context("with the admin operations", func() {
+		BeforeEach(func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{
+					{Name: "v3", Height: "300"},
+					{Name: "v1", Height: "100"},
+					{Name: "v2", Height: "150"},
+				}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 200, nil
+			}
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				return tag == "mainnet-v1", nil
+			}
+		})
+
+		It("should list the plans with their status", func() {
+			plans, err := up.Plans(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plans).To(Equal([]updater.PlanStatus{
+				{Name: "v1", Height: 100, Status: updater.PlanPromoted},
+				{Name: "v2", Height: 150, Status: updater.PlanPending},
+				{Name: "v3", Height: 300, Status: updater.PlanFuture, BlocksRemaining: 100},
+			}))
+		})
+
+		It("should report a plan whose promotion failed", func() {
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				return errors.New("registry unavailable")
+			}
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			plans, err := up.Plans(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plans[1].Name).To(Equal("v2"))
+			Expect(plans[1].Status).To(Equal(updater.PlanFailed))
+			Expect(plans[1].Decision.Action).To(Equal(updater.ActionFailed))
+		})
+
+		It("should report the chain height and the next upgrade", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			chain, err := up.Chain(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chain.Height).To(Equal(int64(200)))
+			Expect(chain.NextUpgradeHeight).To(Equal(int64(300)))
+			Expect(chain.BlocksRemaining).To(Equal(int64(100)))
+		})
+
+		It("should not promote reached upgrades while paused", func() {
+			Expect(up.Pause(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+			Expect(up.Status().Paused).To(BeTrue())
+
+			Expect(up.Resume(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v2", TargetTag: "mainnet-v2"}))
+			Expect(up.Status().Paused).To(BeFalse())
+		})
+
+		It("should keep the pause in the state store across restarts", func() {
+			store := state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+			Expect(up.Pause(ctx)).To(Succeed())
+
+			cfg.PollInterval = time.Hour
+			restarted := updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = restarted.Run(runCtx) }()
+			Eventually(func() bool { return restarted.Status().Paused }).Should(BeTrue())
+			Consistently(mockDockerHubClient.RetagCalls, 100*time.Millisecond).Should(BeEmpty())
+
+			Expect(restarted.Resume(ctx)).To(Succeed())
+			Eventually(mockDockerHubClient.RetagCalls).Should(HaveLen(1))
+			runtime, err := store.GetRuntime(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runtime.Paused).To(BeFalse())
+		})
+
+		It("should list the plans while a check is in progress", func() {
+			started, release := make(chan struct{}), make(chan struct{})
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				close(started)
+				<-release
+				return nil
+			}
+			done := make(chan error, 1)
+			go func() { done <- up.CheckAndProcessUpgrade(ctx) }()
+			defer func() { Eventually(done).Should(Receive(BeNil())) }()
+			defer close(release)
+			Eventually(started).Should(BeClosed())
+
+			plans, err := up.Plans(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plans).To(HaveLen(3))
+			_, err = up.Chain(ctx)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should promote a reached plan on demand, even while paused", func() {
+			Expect(up.Pause(ctx)).To(Succeed())
+			decision, err := up.Promote(ctx, "v2", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decision.Action).To(Equal(updater.ActionRetagged))
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v2", TargetTag: "mainnet-v2"}))
+		})
+
+		It("should only promote a future plan when forced", func() {
+			_, err := up.Promote(ctx, "v3", false)
+			Expect(err).To(MatchError(updater.ErrUpgradeNotReached))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+
+			_, err = up.Promote(ctx, "v3", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v3", TargetTag: "mainnet-v3"}))
+		})
+
+		It("should reject an unknown plan", func() {
+			_, err := up.Promote(ctx, "v4", true)
+			Expect(err).To(MatchError(updater.ErrPlanNotFound))
+		})
+	})
+
+	Context("with manual approval", func() {
+		var notifier *MockNotifier
+
+		BeforeEach(func() {
+			cfg.RequireApproval = true
+			notifier = &MockNotifier{}
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithNotifier(notifier))
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+		})
+
+		approvalEvents := func() []notify.Event {
+			var events []notify.Event
+			for _, event := range notifier.Events() {
+				if event.Type == notify.EventApprovalRequired {
+					events = append(events, event)
+				}
+			}
+			return events
+		}
+
+		It("should wait for approval instead of retagging", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+			decisions := up.Status().Decisions
+			Expect(decisions).To(HaveLen(1))
+			Expect(decisions[0].Action).To(Equal(updater.ActionAwaitingApproval))
+			Expect(decisions[0].AwaitingSince).ToNot(BeZero())
+			Expect(decisions[0].AutoApproveAt).To(BeZero())
+			Expect(approvalEvents()).To(HaveLen(1))
+			Expect(approvalEvents()[0].Images[0].TargetTag).To(Equal("mainnet-v1.2.3"))
+
+			plans, err := up.Plans(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plans[0].Status).To(Equal(updater.PlanAwaitingApproval))
+		})
+
+		It("should promote a plan approved through Approve", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.Approve(ctx, "v1.2.3", "api:alice")).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v1.2.3", TargetTag: "mainnet-v1.2.3"}))
+			Expect(up.Status().Decisions[0].Action).To(Equal(updater.ActionRetagged))
+			Expect(up.Status().Decisions[0].ApprovedBy).To(Equal("api:alice"))
+		})
+
+		It("should promote a plan approved with a file", func() {
+			cfg.ApprovalDir = GinkgoT().TempDir()
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+
+			Expect(os.WriteFile(filepath.Join(cfg.ApprovalDir, "v1.2.3"), nil, 0o600)).To(Succeed())
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(1))
+			Expect(up.Status().Decisions[0].ApprovedBy).To(Equal(updater.ApprovedByFile))
+		})
+
+		It("should approve a plan automatically after the timeout", func() {
+			cfg.ApprovalTimeout = 50 * time.Millisecond
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+			decision := up.Status().Decisions[0]
+			Expect(decision.AutoApproveAt).To(Equal(decision.AwaitingSince.Add(cfg.ApprovalTimeout)))
+
+			Eventually(func() []RetagCall {
+				Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+				return mockDockerHubClient.RetagCalls()
+			}).Should(HaveLen(1))
+			Expect(up.Status().Decisions[0].ApprovedBy).To(Equal(updater.ApprovedByTimeout))
+		})
+
+		It("should retry a failed retag without a new approval", func() {
+			cfg.ApprovalDir = GinkgoT().TempDir()
+			approvalFile := filepath.Join(cfg.ApprovalDir, "v1.2.3")
+			Expect(os.WriteFile(approvalFile, nil, 0o600)).To(Succeed())
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				return errors.New("registry unavailable")
+			}
+			cfg.RetagAttempts = 1
+			Expect(up.CheckAndProcessUpgrade(ctx)).ToNot(Succeed())
+
+			Expect(os.Remove(approvalFile)).To(Succeed())
+			mockDockerHubClient.retagFunc = nil
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.Status().Decisions[0].Action).To(Equal(updater.ActionRetagged))
+		})
+
+		It("should approve a plan when promoting it manually", func() {
+			decision, err := up.Promote(ctx, "v1.2.3", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decision.Action).To(Equal(updater.ActionRetagged))
+			Expect(decision.ApprovedBy).To(Equal(updater.ApprovedByPromote))
+		})
+
+		It("should keep approvals and awaiting times in the state store across restarts", func() {
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}, {Name: "v1.2.4", Height: "200"}}, nil
+			}
+			cfg.ApprovalTimeout = time.Hour
+			cfg.PollInterval = time.Hour
+			cfg.CatchUpPolicy = config.CatchUpAll
+			store := state.NewFileStore(filepath.Join(GinkgoT().TempDir(), "state.json"))
+			up = updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(up.Approve(ctx, "v1.2.4", "api:alice")).To(Succeed())
+			since := up.Status().Decisions[0].AwaitingSince
+
+			restarted := updater.New(mockCosmosClient, mockDockerHubClient, cfg, updater.WithStateStore(store))
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 201, nil
+			}
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = restarted.Run(runCtx) }()
+
+			Eventually(mockDockerHubClient.RetagCalls).Should(ConsistOf(RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v1.2.4", TargetTag: "mainnet-v1.2.4"}))
+			Eventually(restarted.Status).Should(HaveField("Decisions", ConsistOf(
+				SatisfyAll(
+					HaveField("Plan", "v1.2.3"),
+					HaveField("Action", updater.ActionAwaitingApproval),
+					HaveField("AwaitingSince", BeTemporally("==", since)),
+					HaveField("AutoApproveAt", BeTemporally("==", since.Add(time.Hour))),
+				),
+				SatisfyAll(
+					HaveField("Plan", "v1.2.4"),
+					HaveField("Action", updater.ActionRetagged),
+					HaveField("ApprovedBy", "api:alice"),
+				),
+			)))
+		})
+
+		It("should reject approving an unknown plan", func() {
+			Expect(up.Approve(ctx, "v9.9.9", "api:alice")).To(MatchError(updater.ErrPlanNotFound))
+		})
+	})
+
+	Context("with several repositories", func() {
+		BeforeEach(func() {
+			cfg.Repositories = config.Repositories{
+				{Path: "my/repo"},
+				{Path: "my/price-feeder", SourcePrefix: "feeder-release-", TargetPrefix: "feeder-mainnet-"},
+			}
+			cfg.RetagAttempts = 2
+			cfg.RetagBackoff = time.Millisecond
+			mockCosmosClient.getUpgradePlansFunc = func(ctx context.Context) ([]cosmos.Plan, error) {
+				return []cosmos.Plan{{Name: "v1.2.3", Height: "100"}}, nil
+			}
+			mockCosmosClient.getLatestBlockHeightFunc = func(ctx context.Context) (int64, error) {
+				return 101, nil
+			}
+		})
+
+		It("should promote every repository with its own tag rule", func() {
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			Expect(mockDockerHubClient.RetagCalls()).To(ConsistOf(
+				RetagCall{RepoPath: "my/repo", SourceTag: "sha256:release-v1.2.3", TargetTag: "mainnet-v1.2.3"},
+				RetagCall{RepoPath: "my/price-feeder", SourceTag: "sha256:feeder-release-v1.2.3", TargetTag: "feeder-mainnet-v1.2.3"},
+			))
+			decision := up.Status().Decisions[0]
+			Expect(decision.Action).To(Equal(updater.ActionRetagged))
+			Expect(decision.Images).To(HaveLen(2))
+		})
+
+		It("should not retag anything when one source image is missing", func() {
+			mockDockerHubClient.manifestDigestFunc = func(ctx context.Context, repoPath, tag string) (string, error) {
+				if repoPath == "my/price-feeder" {
+					return "", errors.New("manifest unknown")
+				}
+				return "sha256:" + tag, nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("my/price-feeder"))
+			Expect(mockDockerHubClient.RetagCalls()).To(BeEmpty())
+			Expect(up.Status().Decisions[0].Action).To(Equal(updater.ActionFailed))
+		})
+
+		It("should retry a failed retag before giving up", func() {
+			var failures atomic.Int32
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				if repoPath == "my/price-feeder" && failures.Add(1) == 1 {
+					return errors.New("registry boom")
+				}
+				return nil
+			}
+
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+			Expect(mockDockerHubClient.RetagCalls()).To(HaveLen(3))
+		})
+
+		It("should report a partial failure and only retry the missing repository", func() {
+			promoted := map[string]bool{}
+			var mu sync.Mutex
+			mockDockerHubClient.tagExistsFunc = func(ctx context.Context, repoPath, tag string) (bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return promoted[repoPath+":"+tag], nil
+			}
+			feederDown := true
+			mockDockerHubClient.retagFunc = func(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if repoPath == "my/price-feeder" && feederDown {
+					return errors.New("registry boom")
+				}
+				promoted[repoPath+":"+targetTag] = true
+				return nil
+			}
+
+			err := up.CheckAndProcessUpgrade(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("promoted 1 of 2 images"))
+			decision := up.Status().Decisions[0]
+			Expect(decision.Action).To(Equal(updater.ActionPartial))
+			Expect(decision.Images[0].Promoted).To(BeTrue())
+			Expect(decision.Images[1].Promoted).To(BeFalse())
+			Expect(decision.Images[1].Error).To(ContainSubstring("registry boom"))
+
+			mu.Lock()
+			feederDown = false
+			mu.Unlock()
+			Expect(up.CheckAndProcessUpgrade(ctx)).To(Succeed())
+
+			retagCalls := mockDockerHubClient.RetagCalls()
+			// One call for my/repo, then two failed and one successful call for my/price-feeder.
+			Expect(retagCalls).To(HaveLen(4))
+			Expect(retagCalls[3].RepoPath).To(Equal("my/price-feeder"))
+			Expect(up.Status().Decisions[0].Action).To(Equal(updater.ActionRetagged))
+		})
+	})
+})
+
+func gaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	Expect(g.Write(&m)).To(Succeed())
+	return m.GetGauge().GetValue()
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	Expect(c.Write(&m)).To(Succeed())
+	return m.GetCounter().GetValue()
+}
+
+// MockCosmosClient is a mock implementation of the Cosmos client for testing.
+type MockCosmosClient struct {
+	getUpgradePlansFunc      func(ctx context.Context) ([]cosmos.Plan, error)
+	getLatestBlockHeightFunc func(ctx context.Context) (int64, error)
+	getBlockHeaderFunc       func(ctx context.Context, height int64) (*cosmos.BlockHeader, error)
+	getCurrentPlanFunc       func(ctx context.Context) (*cosmos.Plan, error)
+	getAppliedPlanHeightFunc func(ctx context.Context, name string) (int64, error)
+}
+
+func (m *MockCosmosClient) GetUpgradePlans(ctx context.Context) ([]cosmos.Plan, error) {
+	if m.getUpgradePlansFunc != nil {
+		return m.getUpgradePlansFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockCosmosClient) GetLatestBlockHeight(ctx context.Context) (int64, error) {
+	if m.getLatestBlockHeightFunc != nil {
+		return m.getLatestBlockHeightFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *MockCosmosClient) GetBlockHeader(ctx context.Context, height int64) (*cosmos.BlockHeader, error) {
+	if m.getBlockHeaderFunc != nil {
+		return m.getBlockHeaderFunc(ctx, height)
+	}
+	return &cosmos.BlockHeader{Height: strconv.FormatInt(height, 10)}, nil
+}
+
+func (m *MockCosmosClient) GetCurrentPlan(ctx context.Context) (*cosmos.Plan, error) {
+	if m.getCurrentPlanFunc != nil {
+		return m.getCurrentPlanFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockCosmosClient) GetAppliedPlanHeight(ctx context.Context, name string) (int64, error) {
+	if m.getAppliedPlanHeightFunc != nil {
+		return m.getAppliedPlanHeightFunc(ctx, name)
+	}
+	return 0, nil
+}
+
+// MockQuorumClient is a mock Cosmos client that can confirm heights with a quorum of nodes.
+type MockQuorumClient struct {
+	*MockCosmosClient
+	heightReachedFunc func(ctx context.Context, height int64) (bool, error)
+}
+
+func (m *MockQuorumClient) HeightReached(ctx context.Context, height int64) (bool, error) {
+	if m.heightReachedFunc != nil {
+		return m.heightReachedFunc(ctx, height)
+	}
+	return true, nil
+}
+
+// MockChainIDClient is a mock Cosmos client that reports a chain ID.
+type MockChainIDClient struct {
+	*MockCosmosClient
+	chainID string
+	err     error
+	calls   atomic.Int32
+}
+
+func (m *MockChainIDClient) GetChainID(ctx context.Context) (string, error) {
+	m.calls.Add(1)
+	return m.chainID, m.err
+}
+
+// MockDockerHubClient is a mock implementation of the DockerHub client for testing.
+type MockDockerHubClient struct {
+	mu                 sync.Mutex
+	retagCalls         []RetagCall
+	tagExistsFunc      func(ctx context.Context, repoPath, tag string) (bool, error)
+	manifestDigestFunc func(ctx context.Context, repoPath, tag string) (string, error)
+	retagFunc          func(ctx context.Context, repoPath, sourceTag, targetTag string) error
+}
+
+type RetagCall struct {
+	RepoPath  string
+	SourceTag string
+	TargetTag string
+}
+
+func (m *MockDockerHubClient) RetagImage(ctx context.Context, repoPath, sourceTag, targetTag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retagCalls = append(m.retagCalls, RetagCall{RepoPath: repoPath, SourceTag: sourceTag, TargetTag: targetTag})
+	if m.retagFunc != nil {
+		return m.retagFunc(ctx, repoPath, sourceTag, targetTag)
+	}
+	return nil
+}
+
+func (m *MockDockerHubClient) ManifestDigest(ctx context.Context, repoPath, tag string) (string, error) {
+	if m.manifestDigestFunc != nil {
+		return m.manifestDigestFunc(ctx, repoPath, tag)
+	}
+	return "sha256:" + tag, nil
+}
+
+func (m *MockDockerHubClient) TagExists(ctx context.Context, repoPath, tag string) (bool, error) {
+	if m.tagExistsFunc != nil {
+		return m.tagExistsFunc(ctx, repoPath, tag)
+	}
+	return false, nil
+}
+
+func (m *MockDockerHubClient) RetagCalls() []RetagCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.retagCalls
+}
+
+// MockNotifier records the events it is notified about.
+type MockNotifier struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, event notify.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+}
+
+func (m *MockNotifier) Events() []notify.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]notify.Event(nil), m.events...)
+}
+
+// MockSubscriber is a mock block subscriber that delivers heights from a channel.
+type MockSubscriber struct {
+	blocks chan int64
+}
+
+func (m *MockSubscriber) Subscribe(ctx context.Context) <-chan int64 {
+	return m.blocks
 }